@@ -1,110 +1,578 @@
 package deck
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 
 	"github.com/google/uuid"
+
+	"github.com/cretz/go-mental-poker/sra"
 )
 
 // Deck is collection of cards and players. Note, that cards within are from 2
-// to count + 2 due to the requirement that there be no "0" or "1" card.
+// to count + 2 due to the requirement that there be no "0" or "1" card, unless
+// the deck was created with labels.
 type Deck struct {
+	backend sra.Backend
+	// Nil if the backend doesn't support proofs
+	prover  *sra.Prover
 	players []Player
-	count   int
+	// The plain card values before encoding, all > 1
+	values []*big.Int
+	handID uuid.UUID
 	// Must be positive integers > 1
 	cards []*big.Int
+	// Keyed by the encrypted card string, one per player in player order
+	commitments map[string][]*big.Int
+	// Sent with every decrypt request
+	phase string
+	// Plain values of the cards revealed with RevealPublic this hand
+	publicCards []*big.Int
+	// The completed deck as given to ShuffleComplete
+	shuffled []*big.Int
+	// Keyed by the encrypted card string
+	locations map[string]CardLocation
 }
 
-// New creates a new deck for the given player set and count. Note, the cards
-// within the deck range from 2 to count + 2.
-func New(players []Player, count int) *Deck {
-	return &Deck{players: players, count: count}
+// New creates a new deck for the given backend, player set, and count. All
+// players must use the same backend. Note, the cards within the deck range from
+// 2 to count + 2 but they are encoded with backend.EncodeInt before shuffling
+// (e.g. mapped into the quadratic-residue subgroup for safe primes).
+func New(backend sra.Backend, players []Player, count int) *Deck {
+	values := make([]*big.Int, count)
+	for i := range values {
+		values[i] = big.NewInt(int64(i + 2))
+	}
+	return &Deck{backend: backend, prover: backend.Prover(), players: players, values: values}
 }
 
-// ResetAndShuffle first resets the deck to cards 2 to count + 2. Then the
-// three shuffle steps are executed across the players for secure shuffling.
-func (d *Deck) ResetAndShuffle() error {
+// NewWithLabels creates a new deck like New except each card is the given
+// label (e.g. "Ace of spades") instead of an integer. Labels must be unique,
+// non-empty, and short enough for sra.EncodeBytes. Revealed cards are the
+// sra.BytesToInt form of the label, so use sra.IntToBytes to get it back.
+func NewWithLabels(backend sra.Backend, players []Player, labels [][]byte) (*Deck, error) {
+	values := make([]*big.Int, len(labels))
+	seen := make(map[string]bool, len(labels))
+	for i, label := range labels {
+		if seen[string(label)] {
+			return nil, fmt.Errorf("Duplicate label %q", label)
+		}
+		seen[string(label)] = true
+		// Make sure it can be encoded
+		if _, err := sra.EncodeBytes(backend, label); err != nil {
+			return nil, fmt.Errorf("Invalid label %q: %w", label, err)
+		}
+		values[i] = sra.BytesToInt(label)
+	}
+	return &Deck{backend: backend, prover: backend.Prover(), players: players, values: values}, nil
+}
+
+// HandID is the random identifier of the current shuffle. It is uuid.Nil until
+// ResetAndShuffle is called.
+func (d *Deck) HandID() uuid.UUID { return d.handID }
+
+// Phase is the game phase set with SetPhase.
+func (d *Deck) Phase() string { return d.phase }
+
+// SetPhase sets the game phase (e.g. "flop") sent with every decrypt request
+// so player policies can decide based on it. It is not reset by
+// ResetAndShuffle.
+func (d *Deck) SetPhase(phase string) { d.phase = phase }
+
+// ResetAndShuffle first resets the deck to cards 2 to count + 2 (or the
+// labels) under a new hand ID. Then the three shuffle steps are executed across
+// the players for secure shuffling. The context is given to every player call
+// and checked between them. On failure, including cancellation, the deck is
+// left empty and it must be shuffled again.
+func (d *Deck) ResetAndShuffle(ctx context.Context) (err error) {
+	handID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	d.handID = handID
+	d.publicCards = nil
+	defer func() {
+		if err != nil {
+			d.cards = nil
+			d.commitments = nil
+			d.shuffled = nil
+			d.locations = nil
+		}
+	}()
 	// First, reset to 2 to count + 2
-	d.cards = make([]*big.Int, d.count)
-	for i := 0; i < d.count; i++ {
-		d.cards[i] = big.NewInt(int64(i + 2))
+	d.cards = make([]*big.Int, len(d.values))
+	for i, value := range d.values {
+		if d.cards[i], err = d.backend.EncodeInt(value); err != nil {
+			return err
+		}
 	}
 	// Have each player run stage 1 of the shuffle which chains requests for
 	// each to encrypt the entire deck and shuffle it.
 	for _, player := range d.players {
-		if err := player.ShuffleStage1(d.cards); err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
+		} else if err := player.ShuffleStage1(ctx, d.handID, d.cards); err != nil {
+			return &PlayerError{PlayerID: player.ID(), Err: err}
 		}
 	}
 	// Have each player run stage 2 of the shuffle which takes the shuffled
 	// and everyone-encrypted deck and asks each player to re-encrypt their
 	// cards with a key per card.
 	for _, player := range d.players {
-		if err := player.ShuffleStage2(d.cards); err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
+		} else if err := player.ShuffleStage2(ctx, d.cards); err != nil {
+			return &PlayerError{PlayerID: player.ID(), Err: err}
 		}
 	}
 	// Tell each player what the completed deck looks like. This allows them
-	// to map their per-card keys to the full-encrypted card values.
+	// to map their per-card keys to the full-encrypted card values. In return,
+	// they commit to those keys so their decryptions can be checked.
+	if d.commitments, err = d.completeShuffle(ctx, d.cards); err != nil {
+		return err
+	}
+	// Every card starts in the deck
+	d.shuffled = append([]*big.Int(nil), d.cards...)
+	d.locations = make(map[string]CardLocation, len(d.cards))
+	for _, card := range d.cards {
+		d.locations[card.String()] = CardLocation{Zone: ZoneDeck}
+	}
+	return nil
+}
+
+// ReshuffleSubset shuffles the given fully-encrypted cards of the current
+// hand, which must each be in the deck, discard, or burn zone, back into the
+// deck without decrypting them. Cards anywhere else, like players' hands, keep
+// their keys and stay valid. Every player first swaps its per-card key on each
+// card for a single reshuffle key (ReshuffleStart), then every player swaps
+// that for a new key and shuffles (ReshuffleMix), and the usual stage 2 and
+// complete run on the result. The reshuffled cards are new cards in the deck,
+// placed under any deck cards that weren't reshuffled. The old cards have no
+// location afterwards but their commitments are kept so disclosures of their
+// keys can still be verified. Each player is asked about each card as a
+// PurposeReshuffle request and Me refuses cards in its own hand, so a deck
+// that skips the zone check can't turn a hand card into a deck card.
+//
+// On failure, the deck is unchanged. Players drop an incomplete reshuffle and
+// keep their old keys, except players that already completed it if the
+// failure was during ShuffleComplete.
+func (d *Deck) ReshuffleSubset(ctx context.Context, origEncryptedCards []*big.Int) error {
+	if len(origEncryptedCards) == 0 {
+		return fmt.Errorf("No cards to reshuffle")
+	}
+	reshuffled := make(map[string]bool, len(origEncryptedCards))
+	for _, card := range origEncryptedCards {
+		loc, ok := d.locations[card.String()]
+		if !ok {
+			return ErrUnknownCard
+		} else if loc.Zone != ZoneDeck && loc.Zone != ZoneDiscard && loc.Zone != ZoneBurn {
+			return fmt.Errorf("Can't reshuffle card in %v", loc.Zone)
+		} else if reshuffled[card.String()] {
+			return fmt.Errorf("Duplicate card")
+		}
+		reshuffled[card.String()] = true
+	}
+	reqs := make([]*DecryptRequest, len(origEncryptedCards))
+	for i, card := range origEncryptedCards {
+		reqs[i] = d.request(card, PurposeReshuffle, uuid.Nil)
+	}
+	cards := append([]*big.Int(nil), origEncryptedCards...)
+	// Every player swaps their card keys for a reshuffle key, then swaps that
+	// for a new key and shuffles, then does stage 2 as usual
 	for _, player := range d.players {
-		if err := player.ShuffleComplete(d.cards); err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
+		} else if err := player.ReshuffleStart(ctx, reqs, cards); err != nil {
+			return &PlayerError{PlayerID: player.ID(), Err: err}
 		}
 	}
+	for _, player := range d.players {
+		if err := ctx.Err(); err != nil {
+			return err
+		} else if err := player.ReshuffleMix(ctx, cards); err != nil {
+			return &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+	}
+	for _, player := range d.players {
+		if err := ctx.Err(); err != nil {
+			return err
+		} else if err := player.ShuffleStage2(ctx, cards); err != nil {
+			return &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+	}
+	commitments, err := d.completeShuffle(ctx, cards)
+	if err != nil {
+		return err
+	}
+	// Merge the new cards in under the rest of the deck
+	for _, card := range d.cards {
+		if !reshuffled[card.String()] {
+			cards = append(cards, card)
+		}
+	}
+	d.cards = cards
+	shuffled := d.shuffled[:0]
+	for _, card := range d.shuffled {
+		if !reshuffled[card.String()] {
+			shuffled = append(shuffled, card)
+		}
+	}
+	d.shuffled = shuffled
+	for card := range reshuffled {
+		delete(d.locations, card)
+	}
+	for card, cardCommitments := range commitments {
+		d.commitments[card] = cardCommitments
+	}
+	for _, card := range cards[:len(origEncryptedCards)] {
+		d.shuffled = append(d.shuffled, card)
+		d.locations[card.String()] = CardLocation{Zone: ZoneDeck}
+	}
 	return nil
 }
 
+// completeShuffle gives every player the completed cards and returns their
+// commitments keyed by card.
+func (d *Deck) completeShuffle(ctx context.Context, cards []*big.Int) (map[string][]*big.Int, error) {
+	ret := make(map[string][]*big.Int, len(cards))
+	for _, card := range cards {
+		ret[card.String()] = make([]*big.Int, len(d.players))
+	}
+	for i, player := range d.players {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		commitments, err := player.ShuffleComplete(ctx, cards)
+		if err != nil {
+			return nil, &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+		if len(commitments) != len(cards) {
+			return nil, &PlayerError{
+				PlayerID: player.ID(),
+				Err:      fmt.Errorf("Gave %v commitments for %v cards", len(commitments), len(cards)),
+			}
+		}
+		for j, card := range cards {
+			if commitments[j] == nil {
+				return nil, &PlayerError{PlayerID: player.ID(), Err: fmt.Errorf("Gave no commitment for card %v", j)}
+			}
+			ret[card.String()][i] = commitments[j]
+		}
+	}
+	return ret, nil
+}
+
 // DrawCard takes a card off the end of the deck and decrypts it from all
-// players except playerIDToLeaveEncryptedFor (usually the asking player). If
-// playerIDToLeaveEncryptedFor is uuid.Nil or otherwise doesn't match any
-// players, the mostlyDecryptedCard result value will be fully decrypted.
-//
-// Note, in a more serious implementation, checks would be done that confirm
-// who is asking and that they can at a certain time (e.g. it is their turn).
-// Also, the players would be smarter about validating the decryption requests.
+// players except playerIDToLeaveEncryptedFor (usually the asking player), which
+// must be one of the players. Cards every player should see go through
+// RevealPublic instead. The result has the draw request sent to every player,
+// with the fully-encrypted card, for the asking player to decrypt with (e.g.
+// with Me.ReceiveCard). The card is only taken off the deck if every decryption
+// succeeds, and then every player that decrypted it is told with
+// Player.CardDrawn (e.g. so Me can retire its key). A failed draw can only be
+// retried if none of the players that already decrypted the card counted the
+// request (e.g. with NeverTwice), otherwise those players will refuse it and
+// the card is effectively burned. If there are no cards, the error is
+// ErrDeckEmpty.
 func (d *Deck) DrawCard(
+	ctx context.Context,
 	playerIDToLeaveEncryptedFor uuid.UUID,
-) (origEncryptedCard *big.Int, mostlyDecryptedCard *big.Int, err error) {
-	origEncryptedCard = d.cards[len(d.cards)-1]
-	d.cards = d.cards[:len(d.cards)-1]
-	mostlyDecryptedCard, err = d.MostlyRevealCard(origEncryptedCard, playerIDToLeaveEncryptedFor)
+) (req *DecryptRequest, mostlyDecryptedCard *big.Int, err error) {
+	return d.DrawAt(ctx, len(d.cards)-1, playerIDToLeaveEncryptedFor)
+}
+
+// DrawBottom is DrawCard for the card at the bottom of the deck.
+func (d *Deck) DrawBottom(
+	ctx context.Context,
+	playerIDToLeaveEncryptedFor uuid.UUID,
+) (req *DecryptRequest, mostlyDecryptedCard *big.Int, err error) {
+	return d.DrawAt(ctx, 0, playerIDToLeaveEncryptedFor)
+}
+
+// DrawAt is DrawCard for the card at the given position, counted from the
+// bottom of the deck, that the players agreed on. The cards above it move down
+// one position.
+func (d *Deck) DrawAt(
+	ctx context.Context,
+	position int,
+	playerIDToLeaveEncryptedFor uuid.UUID,
+) (req *DecryptRequest, mostlyDecryptedCard *big.Int, err error) {
+	if len(d.cards) == 0 {
+		return nil, nil, ErrDeckEmpty
+	} else if position < 0 || position >= len(d.cards) {
+		return nil, nil, fmt.Errorf("Invalid position %v for %v cards", position, len(d.cards))
+	} else if !d.isPlayer(playerIDToLeaveEncryptedFor) {
+		return nil, nil, fmt.Errorf("Can't draw for unknown player %v", playerIDToLeaveEncryptedFor)
+	}
+	req = d.request(d.cards[position], PurposeDraw, playerIDToLeaveEncryptedFor)
+	if mostlyDecryptedCard, err = d.decrypt(ctx, req, playerIDToLeaveEncryptedFor); err != nil {
+		return nil, nil, err
+	}
+	d.cards = append(d.cards[:position], d.cards[position+1:]...)
+	d.locations[req.Card.String()] = CardLocation{Zone: ZoneHand, Owner: playerIDToLeaveEncryptedFor}
+	d.cardsDrawn(ctx, []*DecryptRequest{req}, playerIDToLeaveEncryptedFor)
+	return
+}
+
+// DrawN is DrawCard for the n cards off the end of the deck, in the order they
+// would be drawn one at a time. Each player is asked to decrypt all of them in
+// a single DecryptCards call instead of one call per card. The cards are only
+// taken off the deck if every decryption succeeds and, like DrawCard, the
+// players are then told with Player.CardDrawn and a failed draw may leave them
+// burned for the players that already counted them. If there are fewer than n
+// cards, the error is ErrDeckEmpty. Like DrawCard, playerIDToLeaveEncryptedFor
+// must be one of the players.
+func (d *Deck) DrawN(
+	ctx context.Context,
+	n int,
+	playerIDToLeaveEncryptedFor uuid.UUID,
+) (reqs []*DecryptRequest, mostlyDecryptedCards []*big.Int, err error) {
+	if n < 1 {
+		return nil, nil, fmt.Errorf("Invalid card count %v", n)
+	} else if n > len(d.cards) {
+		return nil, nil, fmt.Errorf("Have %v cards, need %v: %w", len(d.cards), n, ErrDeckEmpty)
+	} else if !d.isPlayer(playerIDToLeaveEncryptedFor) {
+		return nil, nil, fmt.Errorf("Can't draw for unknown player %v", playerIDToLeaveEncryptedFor)
+	}
+	reqs = make([]*DecryptRequest, n)
+	for i := range reqs {
+		reqs[i] = d.request(d.cards[len(d.cards)-1-i], PurposeDraw, playerIDToLeaveEncryptedFor)
+	}
+	if mostlyDecryptedCards, err = d.decryptAll(ctx, reqs, playerIDToLeaveEncryptedFor); err != nil {
+		return nil, nil, err
+	}
+	d.cards = d.cards[:len(d.cards)-n]
+	for _, req := range reqs {
+		d.locations[req.Card.String()] = CardLocation{Zone: ZoneHand, Owner: playerIDToLeaveEncryptedFor}
+	}
+	d.cardsDrawn(ctx, reqs, playerIDToLeaveEncryptedFor)
 	return
 }
 
+// cardsDrawn tells every player except playerIDToLeaveEncryptedFor that the
+// draws went through.
+func (d *Deck) cardsDrawn(ctx context.Context, reqs []*DecryptRequest, playerIDToLeaveEncryptedFor uuid.UUID) {
+	for _, player := range d.players {
+		if player.ID() != playerIDToLeaveEncryptedFor {
+			for _, req := range reqs {
+				player.CardDrawn(ctx, req)
+			}
+		}
+	}
+}
+
 // MostlyRevealCard takes the given fully-encrypted card and decrypts it from
-// all players except playerIDToLeaveEncryptedFor (usually the asking player).
-// If playerIDToLeaveEncryptedFor is uuid.Nil or otherwise doesn't match any
-// players, the mostlyDecryptedCard result value will be fully decrypted.
+// all players except playerIDToLeaveEncryptedFor. If it is a player, the
+// request is a draw for them. If playerIDToLeaveEncryptedFor is uuid.Nil or
+// otherwise doesn't match any players, the request is an end-of-game reveal
+// on no player's behalf and the mostlyDecryptedCard result value will be fully
+// decrypted.
+//
+// A player that fails to decrypt is named in a PlayerError. If the backend has
+// a prover, each player's decryption is verified against their commitment for
+// the card as it arrives and a bad proof is also a PlayerError. If the card is
+// not from the current shuffle, the error is ErrUnknownCard.
 //
-// Note, this is exposed for debugging purposes and in a more serious
-// implementation it would not even exist and no reasonable-written player
-// would let this caller decrypt a card whenever it wanted.
+// Note, this is exposed for debugging purposes. Players with a Policy can
+// refuse it like any other request.
 func (d *Deck) MostlyRevealCard(
+	ctx context.Context,
 	origEncryptedCard *big.Int,
 	playerIDToLeaveEncryptedFor uuid.UUID,
 ) (mostlyDecryptedCard *big.Int, err error) {
-	mostlyDecryptedCard = origEncryptedCard
-	// Decrypt the card from all other players but the given one
+	purpose := PurposeEndOfGame
+	requesterID := uuid.Nil
 	for _, player := range d.players {
-		if player.ID() != playerIDToLeaveEncryptedFor {
-			mostlyDecryptedCard = player.DecryptCard(origEncryptedCard, mostlyDecryptedCard)
-			if mostlyDecryptedCard == nil {
-				return nil, fmt.Errorf("No decrypted card from %v", player)
+		if player.ID() == playerIDToLeaveEncryptedFor {
+			purpose, requesterID = PurposeDraw, player.ID()
+		}
+	}
+	return d.decrypt(ctx, d.request(origEncryptedCard, purpose, requesterID), playerIDToLeaveEncryptedFor)
+}
+
+// request builds a decrypt request for the card in the current hand.
+func (d *Deck) request(card *big.Int, purpose Purpose, requesterID uuid.UUID) *DecryptRequest {
+	req := &DecryptRequest{
+		RequesterID: requesterID,
+		HandID:      d.handID,
+		Card:        card,
+		Position:    -1,
+		Purpose:     purpose,
+		Phase:       d.phase,
+	}
+	for i, deckCard := range d.cards {
+		if deckCard.Cmp(card) == 0 {
+			req.Position = i
+		}
+	}
+	return req
+}
+
+// decrypt has every player except playerIDToLeaveEncryptedFor decrypt the
+// request's card in player order, verifying proofs if there is a prover.
+func (d *Deck) decrypt(
+	ctx context.Context,
+	req *DecryptRequest,
+	playerIDToLeaveEncryptedFor uuid.UUID,
+) (mostlyDecryptedCard *big.Int, err error) {
+	commitments := d.commitments[req.Card.String()]
+	if commitments == nil {
+		return nil, ErrUnknownCard
+	}
+	mostlyDecryptedCard = req.Card
+	// Decrypt the card from all other players but the given one
+	for i, player := range d.players {
+		if player.ID() == playerIDToLeaveEncryptedFor {
+			continue
+		} else if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		decrypted, proof, err := player.DecryptCard(ctx, req, mostlyDecryptedCard)
+		if err == nil {
+			err = d.checkDecryption(commitments[i], mostlyDecryptedCard, decrypted, proof)
+		}
+		if err != nil {
+			return nil, &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+		mostlyDecryptedCard = decrypted
+	}
+	return
+}
+
+// decryptAll is decrypt for several requests at once with a single
+// DecryptCards call per player.
+func (d *Deck) decryptAll(
+	ctx context.Context,
+	reqs []*DecryptRequest,
+	playerIDToLeaveEncryptedFor uuid.UUID,
+) (mostlyDecryptedCards []*big.Int, err error) {
+	commitments := make([][]*big.Int, len(reqs))
+	mostlyDecryptedCards = make([]*big.Int, len(reqs))
+	for i, req := range reqs {
+		if commitments[i] = d.commitments[req.Card.String()]; commitments[i] == nil {
+			return nil, ErrUnknownCard
+		}
+		mostlyDecryptedCards[i] = req.Card
+	}
+	for i, player := range d.players {
+		if player.ID() == playerIDToLeaveEncryptedFor {
+			continue
+		} else if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		decrypted, proofs, err := player.DecryptCards(ctx, reqs, mostlyDecryptedCards)
+		if err == nil && (len(decrypted) != len(reqs) || (d.prover != nil && len(proofs) != len(reqs))) {
+			err = fmt.Errorf("Gave %v decryptions and %v proofs for %v cards", len(decrypted), len(proofs), len(reqs))
+		}
+		for j := 0; err == nil && j < len(reqs); j++ {
+			var proof *sra.DecryptProof
+			if j < len(proofs) {
+				proof = proofs[j]
+			}
+			if err = d.checkDecryption(commitments[j][i], mostlyDecryptedCards[j], decrypted[j], proof); err != nil {
+				err = fmt.Errorf("Card %v: %w", j, err)
 			}
 		}
+		if err != nil {
+			return nil, &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+		mostlyDecryptedCards = decrypted
 	}
 	return
 }
 
+// checkDecryption checks a player's decryption of val against their
+// commitment for the card if there is a prover.
+func (d *Deck) checkDecryption(commitment, val, decrypted *big.Int, proof *sra.DecryptProof) error {
+	if decrypted == nil {
+		return fmt.Errorf("No decrypted card")
+	} else if d.prover != nil {
+		if err := d.prover.VerifyDecrypt(commitment, val, decrypted, proof); err != nil {
+			return fmt.Errorf("Invalid decryption: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevealPublic takes a card off the end of the deck, decrypts it with every
+// player in a PurposePublicReveal request, and tells every player the plain
+// value. The value is returned and recorded in PublicCards. Like DrawCard, the
+// card is only taken off the deck if every decryption succeeds. If a player
+// rejects the plain value, the error is a PlayerError but the card is still
+// taken off and recorded since every player has already decrypted it.
+func (d *Deck) RevealPublic(ctx context.Context) (*big.Int, error) {
+	if len(d.cards) == 0 {
+		return nil, ErrDeckEmpty
+	}
+	req := d.request(d.cards[len(d.cards)-1], PurposePublicReveal, uuid.Nil)
+	card, err := d.decrypt(ctx, req, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	if card, err = d.backend.DecodeInt(card); err != nil {
+		return nil, err
+	}
+	d.cards = d.cards[:len(d.cards)-1]
+	d.locations[req.Card.String()] = CardLocation{Zone: ZoneTableFaceUp}
+	d.publicCards = append(d.publicCards, card)
+	for _, player := range d.players {
+		if err := player.PublicCardRevealed(ctx, req, card); err != nil {
+			return card, &PlayerError{PlayerID: player.ID(), Err: err}
+		}
+	}
+	return card, nil
+}
+
+// PublicCards returns the plain values of the cards revealed with
+// RevealPublic in the current hand, in reveal order.
+func (d *Deck) PublicCards() []*big.Int { return append([]*big.Int(nil), d.publicCards...) }
+
+// Commitments returns every player's commitment, in player order, to their key
+// for the fully-encrypted card, or nil if the card is not from the current
+// shuffle.
+func (d *Deck) Commitments(origEncryptedCard *big.Int) []*big.Int {
+	return append([]*big.Int(nil), d.commitments[origEncryptedCard.String()]...)
+}
+
+// VerifyDisclosure checks that every key a player disclosed for the current
+// hand matches the commitment they made for it at the end of the shuffle.
+func (d *Deck) VerifyDisclosure(disclosure *Disclosure) error {
+	if disclosure.HandID != d.handID {
+		return fmt.Errorf("Disclosure is for another hand")
+	}
+	playerIndex := -1
+	for i, player := range d.players {
+		if player.ID() == disclosure.PlayerID {
+			playerIndex = i
+		}
+	}
+	if playerIndex < 0 {
+		return fmt.Errorf("Disclosure is from unknown player %v", disclosure.PlayerID)
+	}
+	for card, key := range disclosure.Keys {
+		commitments := d.commitments[card]
+		if commitments == nil {
+			return fmt.Errorf("Disclosure has unknown card: %w", ErrUnknownCard)
+		}
+		if err := sra.VerifyCommitment(d.backend, commitments[playerIndex], key); err != nil {
+			return &PlayerError{PlayerID: disclosure.PlayerID, Err: fmt.Errorf("Invalid key disclosed: %w", err)}
+		}
+	}
+	return nil
+}
+
 // RevealCards returns the revealed cards in the deck. This is only for
-// debugging purposes and in a real-world implementation this would not exist
-// and not be possible because the players would balk at decryption requests.
-func (d *Deck) RevealCards() (revealed []*big.Int, err error) {
+// debugging purposes and in a real-world implementation this would not exist.
+// It sends end-of-game requests, so players with a Policy can refuse it.
+func (d *Deck) RevealCards(ctx context.Context) (revealed []*big.Int, err error) {
 	revealed = make([]*big.Int, len(d.cards))
 	for i, card := range d.cards {
 		// Ask to reveal from all players
-		if revealed[i], err = d.MostlyRevealCard(card, uuid.Nil); err != nil {
+		if revealed[i], err = d.MostlyRevealCard(ctx, card, uuid.Nil); err != nil {
+			break
+		}
+		if revealed[i], err = d.backend.DecodeInt(revealed[i]); err != nil {
 			break
 		}
 	}
@@ -1,17 +1,21 @@
 package deck_test
 
 import (
-	"crypto/rand"
-	"log"
-	"math/big"
+	"context"
 	"testing"
 
 	"github.com/cretz/go-mental-poker/deck"
+	"github.com/cretz/go-mental-poker/sra"
 )
 
 // Testing has shown prime size doesn't matter that much
-var prime = genPrime(1024)
+var params = sra.MODP2048
+var backendsByBits = map[int]sra.Backend{}
 var playersByBits = map[int][]deck.Player{}
+var defaultBackend = &sra.SRABackend{Params: params}
+var defaultPlayers []deck.Player
+var serialPlayers []deck.Player
+var ecPlayers []deck.Player
 
 func BenchmarkShuffle2Players32Bits52Cards(b *testing.B) {
 	benchmarkShuffle(b, 2, 32, 52)
@@ -51,31 +55,84 @@ func BenchmarkShuffle6Players64Bits104Cards(b *testing.B) {
 	benchmarkShuffle(b, 6, 64, 104)
 }
 
+func BenchmarkShuffleDefault2Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, defaultPlayers[:2], 52)
+}
+func BenchmarkShuffleDefault3Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, defaultPlayers[:3], 52)
+}
+func BenchmarkShuffleDefault2Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, defaultPlayers[:2], 104)
+}
+func BenchmarkShuffleDefault3Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, defaultPlayers[:3], 104)
+}
+
+func BenchmarkShuffleSerial3Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, serialPlayers[:3], 52)
+}
+func BenchmarkShuffleSerial3Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, defaultBackend, serialPlayers[:3], 104)
+}
+
+func BenchmarkShuffleEC2Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:2], 52)
+}
+func BenchmarkShuffleEC3Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:3], 52)
+}
+func BenchmarkShuffleEC6Players52Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:6], 52)
+}
+func BenchmarkShuffleEC2Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:2], 104)
+}
+func BenchmarkShuffleEC3Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:3], 104)
+}
+func BenchmarkShuffleEC6Players104Cards(b *testing.B) {
+	benchmarkShuffleBackend(b, sra.P256, ecPlayers[:6], 104)
+}
+
 var benchErr error
 
 func benchmarkShuffle(b *testing.B, playerCount int, bits int, cardCount int) {
+	benchmarkShuffleBackend(b, backendsByBits[bits], playersByBits[bits][:playerCount], cardCount)
+}
+
+func benchmarkShuffleBackend(b *testing.B, backend sra.Backend, players []deck.Player, cardCount int) {
 	var err error
 	for i := 0; i < b.N; i++ {
-		d := deck.New(playersByBits[bits][:playerCount], cardCount)
-		err = d.ResetAndShuffle()
+		d := deck.New(backend, players, cardCount)
+		err = d.ResetAndShuffle(context.Background())
 	}
 	benchErr = err
 }
 
 func init() {
 	for _, bits := range []int{32, 64} {
+		backendsByBits[bits] = &sra.SRABackend{
+			Params:  params,
+			Options: sra.GenerateOptions{PrimeExponentBits: bits, NoSwap: true},
+		}
 		players := make([]deck.Player, 40)
 		for i := 0; i < len(players); i++ {
-			players[i] = deck.NewMe(prime, bits)
+			players[i] = deck.NewMe(backendsByBits[bits])
 		}
 		playersByBits[bits] = players
 	}
-}
-
-func genPrime(bits int) *big.Int {
-	ret, err := rand.Prime(rand.Reader, bits)
-	if err != nil {
-		log.Fatal(err)
+	defaultPlayers = make([]deck.Player, 40)
+	for i := 0; i < len(defaultPlayers); i++ {
+		defaultPlayers[i] = deck.NewMe(defaultBackend)
+	}
+	serialPlayers = make([]deck.Player, 40)
+	for i := 0; i < len(serialPlayers); i++ {
+		me := deck.NewMe(defaultBackend)
+		me.Workers = 1
+		serialPlayers[i] = me
+	}
+	ecPlayers = make([]deck.Player, 40)
+	for i := 0; i < len(ecPlayers); i++ {
+		ecPlayers[i] = deck.NewMe(sra.P256)
 	}
-	return ret
 }
@@ -1,14 +1,23 @@
 package deck_test
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/cretz/go-mental-poker/deck"
+	"github.com/cretz/go-mental-poker/internal/testutil"
+	"github.com/cretz/go-mental-poker/sra"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,27 +30,26 @@ func TestSimpleDraw(t *testing.T) {
 	}
 	fmt.Printf("%-19v %v\n", "All cards:", allCardsInOrder)
 
-	// Create a prime everyone shares
-	sharedPrime, err := rand.Prime(rand.Reader, 256)
-	require.NoError(t, err)
+	// Use SRA keys over a well-known group everyone shares
+	backend := &sra.SRABackend{Params: sra.MODP2048}
 
 	// Create three players
-	alice := deck.NewMe(sharedPrime, 32)
-	bob := deck.NewMe(sharedPrime, 32)
-	ted := deck.NewMe(sharedPrime, 32)
+	alice := deck.NewMe(backend)
+	bob := deck.NewMe(backend)
+	ted := deck.NewMe(backend)
 
 	// Create a deck of cards
-	d := deck.New([]deck.Player{alice, bob, ted}, 52)
+	d := deck.New(backend, []deck.Player{alice, bob, ted}, 52)
 
 	// Do a shuffle
-	require.NoError(t, d.ResetAndShuffle())
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
 	fmt.Printf("%-19v %v\n", "Deck after shuffle:", deckCards(t, d))
 
 	// Give each player 7 cards
 	for i := 0; i < 7; i++ {
-		require.NoError(t, alice.DrawCard(d))
-		require.NoError(t, bob.DrawCard(d))
-		require.NoError(t, ted.DrawCard(d))
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+		require.NoError(t, bob.DrawCard(context.Background(), d))
+		require.NoError(t, ted.DrawCard(context.Background(), d))
 	}
 	fmt.Printf("%-19v %v\n", "Deck after draws:", deckCards(t, d))
 	fmt.Printf("%-19v %v\n", "Alice's draw:", playerCards(t, alice))
@@ -56,6 +64,843 @@ func TestSimpleDraw(t *testing.T) {
 	require.ElementsMatch(t, allCardsInOrder, finalCards)
 }
 
+// TestQRDeckHidesLegendreSymbol confirms the Legendre symbol of encrypted cards
+// matches their plaintext when not using a safe prime but not when using one
+func TestQRDeckHidesLegendreSymbol(t *testing.T) {
+	// With a regular prime, every encrypted card has the same symbol as its
+	// plaintext card
+	prime := testutil.NewUnsafePrime(t, 256)
+	backend := &sra.SRABackend{Params: sra.NewUncheckedParams(prime)}
+	require.Nil(t, backend.Params.Q)
+	alice, bob := deck.NewMe(backend), deck.NewMe(backend)
+	d := deck.New(backend, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	for i := 0; i < 52; i++ {
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+	}
+	classes := map[int]int{}
+	for i, card := range alice.DecryptedCards {
+		symbol := big.Jacobi(card, prime)
+		classes[symbol]++
+		require.Equal(t, symbol, big.Jacobi(alice.OrigEncryptedCards[i], prime))
+	}
+	require.Len(t, classes, 2)
+
+	// With a safe prime, every encrypted card is a residue
+	safePrime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	backend = &sra.SRABackend{Params: sra.NewUncheckedParams(safePrime)}
+	require.NotNil(t, backend.Params.Q)
+	alice, bob = deck.NewMe(backend), deck.NewMe(backend)
+	d = deck.New(backend, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	for i := 0; i < 52; i++ {
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+	}
+	for _, card := range alice.OrigEncryptedCards {
+		require.Equal(t, 1, big.Jacobi(card, safePrime))
+	}
+	require.ElementsMatch(t, allCards(), playerCards(t, alice))
+}
+
+// TestShuffleRejectsNonResidues confirms a card negated out of the residue
+// subgroup, which would be the only non-residue after an honest player's
+// shuffle, is refused instead of encrypted
+func TestShuffleRejectsNonResidues(t *testing.T) {
+	backend := &sra.SRABackend{Params: sra.MODP2048}
+	cards := make([]*big.Int, 52)
+	for i := range cards {
+		var err error
+		cards[i], err = backend.EncodeInt(big.NewInt(int64(i + 2)))
+		require.NoError(t, err)
+	}
+	cards[7] = new(big.Int).Sub(sra.MODP2048.P, cards[7])
+	handID, err := uuid.NewRandom()
+	require.NoError(t, err)
+	require.Error(t, deck.NewMe(backend).ShuffleStage1(context.Background(), handID, cards))
+}
+
+// TestECDraw confirms the elliptic-curve backend can be used in place of SRA
+func TestECDraw(t *testing.T) {
+	alice, bob, ted := deck.NewMe(sra.P256), deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	d := deck.New(sra.P256, []deck.Player{alice, bob, ted}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+		require.NoError(t, bob.DrawCard(context.Background(), d))
+	}
+	finalCards := append([]Card{}, deckCards(t, d)...)
+	finalCards = append(finalCards, playerCards(t, alice)...)
+	finalCards = append(finalCards, playerCards(t, bob)...)
+	require.ElementsMatch(t, allCards(), finalCards)
+}
+
+// TestKeyGenExhausted confirms bad key options fail the shuffle instead of
+// spinning forever
+func TestKeyGenExhausted(t *testing.T) {
+	backend := &sra.SRABackend{
+		Params:  sra.MODP2048,
+		Options: sra.GenerateOptions{MinExponentBits: 4096, MaxAttempts: 10},
+	}
+	d := deck.New(backend, []deck.Player{deck.NewMe(backend), deck.NewMe(backend)}, 52)
+	require.True(t, errors.Is(d.ResetAndShuffle(context.Background()), sra.ErrKeyGenExhausted))
+}
+
+// TestRestoreHand confirms players with a master secret can rebuild and
+// disclose their card keys after losing them
+func TestRestoreHand(t *testing.T) {
+	backend := &sra.SRABackend{Params: sra.MODP2048}
+	secret := make([]byte, deck.MinMasterSecretSize)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	id, err := uuid.NewRandom()
+	require.NoError(t, err)
+	alice, err := deck.NewMeWithSecret(backend, id, secret)
+	require.NoError(t, err)
+	recorder := &completeRecorder{Player: alice}
+	d := deck.New(backend, []deck.Player{recorder, deck.NewMe(backend)}, 10)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+
+	// A fresh player with the same secret and hand salt can rebuild every key
+	restored, err := deck.NewMeWithSecret(backend, id, secret)
+	require.NoError(t, err)
+	require.Len(t, alice.HandSalt(), deck.HandSaltSize)
+	require.NoError(t, restored.RestoreHand(d.HandID(), alice.HandSalt(), recorder.cards))
+	for _, card := range recorder.cards {
+		require.NotNil(t, alice.CardKey(card))
+		require.Equal(t, alice.CardKey(card), restored.CardKey(card))
+	}
+	require.Nil(t, restored.CardKey(big.NewInt(2)))
+
+	// Replaying the hand ID gives entirely new keys
+	replayed := append([]*big.Int(nil), recorder.cards...)
+	require.NoError(t, alice.ShuffleStage1(context.Background(), d.HandID(), replayed))
+	require.NoError(t, alice.ShuffleStage2(context.Background(), replayed))
+	// Complete with the original cards so keys are comparable
+	_, err = alice.ShuffleComplete(context.Background(), recorder.cards)
+	require.NoError(t, err)
+	for _, card := range recorder.cards {
+		require.NotEqual(t, restored.CardKey(card), alice.CardKey(card))
+	}
+
+	// Secretless players can't restore, the salt is required, and short
+	// secrets are rejected
+	require.Error(t, deck.NewMe(backend).RestoreHand(d.HandID(), alice.HandSalt(), recorder.cards))
+	require.Error(t, restored.RestoreHand(d.HandID(), nil, recorder.cards))
+	_, err = deck.NewMeWithSecret(backend, id, secret[1:])
+	require.Error(t, err)
+}
+
+// TestRetireAfterUse confirms used keys are only available for disclosure
+// until the hand is closed
+func TestRetireAfterUse(t *testing.T) {
+	alice, bob, ted := deck.NewMe(sra.P256), deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	alice.RetireUsedKeys, bob.RetireUsedKeys = true, true
+	d := deck.New(sra.P256, []deck.Player{alice, bob, ted}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+	card := alice.OrigEncryptedCards[0]
+
+	// Nobody but ted will decrypt it again
+	req := &deck.DecryptRequest{HandID: d.HandID(), Card: card, Position: -1, Purpose: deck.PurposeEndOfGame}
+	for _, player := range []*deck.Me{alice, bob, ted} {
+		_, _, err := player.DecryptCard(context.Background(), req, card)
+		require.Equal(t, player != ted, errors.Is(err, deck.ErrRefused))
+	}
+	_, err := d.MostlyRevealCard(context.Background(), card, uuid.Nil)
+	var playerErr *deck.PlayerError
+	require.True(t, errors.As(err, &playerErr))
+	require.Equal(t, alice.ID(), playerErr.PlayerID)
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	_, err = d.MostlyRevealCard(context.Background(), big.NewInt(2), uuid.Nil)
+	require.True(t, errors.Is(err, deck.ErrUnknownCard))
+	req = &deck.DecryptRequest{HandID: d.HandID(), Card: big.NewInt(2), Position: -1}
+	_, _, err = ted.DecryptCard(context.Background(), req, big.NewInt(2))
+	require.True(t, errors.Is(err, deck.ErrUnknownCard))
+
+	// But the keys are in the disclosure
+	disclosure := bob.Disclosure()
+	require.Equal(t, bob.ID(), disclosure.PlayerID)
+	require.Equal(t, d.HandID(), disclosure.HandID)
+	require.Len(t, disclosure.Keys, 1)
+	key := disclosure.Keys[card.String()]
+	require.NotNil(t, key)
+	require.Nil(t, bob.CardKey(card))
+	require.NotNil(t, ted.CardKey(card))
+	require.Empty(t, ted.Disclosure().Keys)
+
+	// Closing the hand destroys them
+	bob.CloseHand()
+	require.Empty(t, bob.Disclosure().Keys)
+	require.Nil(t, bob.CardKey(card))
+	require.Zero(t, key.(*sra.ECKeyPair).Enc.Sign())
+}
+
+// TestRetireAfterReveal confirms keys are only retired once the card is
+// revealed, so a draw refused by a later player can be retried
+func TestRetireAfterReveal(t *testing.T) {
+	alice, bob, ted := deck.NewMe(sra.P256), deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	alice.RetireUsedKeys, bob.RetireUsedKeys = true, true
+	refuse := true
+	ted.Policy = deck.PolicyFunc(func(req *deck.DecryptRequest) error {
+		if refuse {
+			return fmt.Errorf("Not yet")
+		}
+		return nil
+	})
+	d := deck.New(sra.P256, []deck.Player{alice, bob, ted}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+
+	// Bob decrypts before ted refuses, but keeps the key
+	card := d.CardsIn(deck.ZoneDeck, uuid.Nil)[51]
+	err := alice.DrawCard(context.Background(), d)
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	require.NotNil(t, bob.CardKey(card))
+	require.Empty(t, bob.Disclosure().Keys)
+
+	// So the draw can be retried, and then both keys are retired
+	refuse = false
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+	require.Equal(t, card, alice.OrigEncryptedCards[0])
+	require.Nil(t, alice.CardKey(card))
+	require.Nil(t, bob.CardKey(card))
+	require.NotNil(t, ted.CardKey(card))
+	require.Len(t, bob.Disclosure().Keys, 1)
+
+	// Public cards are retired once revealed
+	card = d.CardsIn(deck.ZoneDeck, uuid.Nil)[50]
+	_, err = d.RevealPublic(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, alice.CardKey(card))
+	require.Nil(t, bob.CardKey(card))
+	require.Len(t, bob.Disclosure().Keys, 2)
+}
+
+// TestVerifyDisclosure confirms disclosed keys are checked against the
+// commitments from the shuffle
+func TestVerifyDisclosure(t *testing.T) {
+	prime := testutil.NewUnsafePrime(t, 256)
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.NewUncheckedParams(prime)}, sra.P256} {
+		alice, bob := deck.NewMe(backend), deck.NewMe(backend)
+		alice.RetireUsedKeys, bob.RetireUsedKeys = true, true
+		d := deck.New(backend, []deck.Player{alice, bob}, 10)
+		require.NoError(t, d.ResetAndShuffle(context.Background()))
+		for i := 0; i < 3; i++ {
+			require.NoError(t, alice.DrawCard(context.Background(), d))
+			require.NoError(t, bob.DrawCard(context.Background(), d))
+		}
+		require.Len(t, d.Commitments(alice.OrigEncryptedCards[0]), 2)
+		aliceDisclosure, bobDisclosure := alice.Disclosure(), bob.Disclosure()
+		require.Len(t, aliceDisclosure.Keys, 6)
+		require.NoError(t, d.VerifyDisclosure(aliceDisclosure))
+		require.NoError(t, d.VerifyDisclosure(bobDisclosure))
+
+		// Alice claiming bob's keys fails
+		require.Error(t, d.VerifyDisclosure(&deck.Disclosure{
+			PlayerID: alice.ID(),
+			HandID:   d.HandID(),
+			Keys:     bobDisclosure.Keys,
+		}))
+		// So does disclosing for another hand
+		require.NoError(t, d.ResetAndShuffle(context.Background()))
+		require.Error(t, d.VerifyDisclosure(aliceDisclosure))
+	}
+}
+
+// TestLabeledDeck confirms cards can be byte-string labels instead of ints
+func TestLabeledDeck(t *testing.T) {
+	labels := make([][]byte, 52)
+	for i := range labels {
+		labels[i] = []byte(CardFromInt(i).String())
+	}
+	alice, bob := deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	d, err := deck.NewWithLabels(sra.P256, []deck.Player{alice, bob}, labels)
+	require.NoError(t, err)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+	}
+	revealed, err := d.RevealCards(context.Background())
+	require.NoError(t, err)
+	var finalLabels [][]byte
+	for _, card := range append(revealed, alice.DecryptedCards...) {
+		label, err := sra.IntToBytes(card)
+		require.NoError(t, err)
+		finalLabels = append(finalLabels, label)
+	}
+	require.ElementsMatch(t, labels, finalLabels)
+
+	// Bad labels
+	_, err = deck.NewWithLabels(sra.P256, nil, [][]byte{[]byte("A"), []byte("A")})
+	require.Error(t, err)
+	_, err = deck.NewWithLabels(sra.P256, nil, [][]byte{{}})
+	require.Error(t, err)
+	_, err = deck.NewWithLabels(sra.P256, nil, [][]byte{make([]byte, 31)})
+	require.Error(t, err)
+}
+
+// TestJointParams confirms players can shuffle with a prime none of them
+// picked alone
+func TestJointParams(t *testing.T) {
+	setups := make([]*sra.ParamsSetup, 2)
+	commitments := make([][]byte, len(setups))
+	for i := range setups {
+		var err error
+		setups[i], err = sra.NewParamsSetup(rand.Reader, 256)
+		require.NoError(t, err)
+		commitments[i] = setups[i].Commitment()
+	}
+	seeds := make([][]byte, len(setups))
+	for i, setup := range setups {
+		var err error
+		seeds[i], err = setup.Reveal(commitments)
+		require.NoError(t, err)
+	}
+	players := make([]deck.Player, len(setups))
+	var backend sra.Backend
+	for i, setup := range setups {
+		params, err := setup.UncheckedParams(context.Background(), seeds)
+		require.NoError(t, err)
+		backend = &sra.SRABackend{Params: params}
+		players[i] = deck.NewMe(backend)
+	}
+	d := deck.New(backend, players, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+}
+
+// TestParallelCardKeys confirms per-card keys don't depend on the number of
+// workers that generated them
+func TestParallelCardKeys(t *testing.T) {
+	secret := make([]byte, deck.MinMasterSecretSize)
+	id, handID := uuid.New(), uuid.New()
+	var cards []*big.Int
+	for i := 0; i < 20; i++ {
+		card, err := sra.P256.EncodeInt(big.NewInt(int64(i + 2)))
+		require.NoError(t, err)
+		cards = append(cards, card)
+	}
+	for _, workers := range []int{1, 4, 0} {
+		me, err := deck.NewMeWithSecret(sra.P256, id, secret)
+		require.NoError(t, err)
+		me.Workers = workers
+		deckCards := append([]*big.Int(nil), cards...)
+		require.NoError(t, me.ShuffleStage1(context.Background(), handID, deckCards))
+		require.NoError(t, me.ShuffleStage2(context.Background(), deckCards))
+		// Complete with the original cards so keys are comparable
+		_, err = me.ShuffleComplete(context.Background(), cards)
+		require.NoError(t, err)
+		// Same keys as restoring them one by one
+		restored, err := deck.NewMeWithSecret(sra.P256, id, secret)
+		require.NoError(t, err)
+		require.NoError(t, restored.RestoreHand(handID, me.HandSalt(), cards))
+		for _, card := range cards {
+			require.Equal(t, restored.CardKey(card), me.CardKey(card))
+		}
+	}
+}
+
+// TestShuffleStage2Failure confirms failed key generation reports every error
+// and leaves the player able to shuffle again
+func TestShuffleStage2Failure(t *testing.T) {
+	backend := &failingBackend{Backend: sra.P256}
+	me := deck.NewMe(backend)
+	me.Workers = 4
+	d := deck.New(backend, []deck.Player{me}, 52)
+	// Fail every key after stage 1
+	backend.failAfter = 1
+	err := d.ResetAndShuffle(context.Background())
+	require.True(t, errors.Is(err, errKeyGen))
+	require.Contains(t, err.Error(), "Key for card")
+	// Works again once keys can be generated
+	backend.failAfter = -1
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+}
+
+// TestCanceledShuffle confirms a canceled shuffle or draw leaves the deck and
+// players able to continue
+func TestCanceledShuffle(t *testing.T) {
+	alice := deck.NewMe(sra.P256)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Cancel once bob is done with stage 1, leaving alice waiting on stage 2
+	bob := &cancelingPlayer{Player: deck.NewMe(sra.P256), cancel: cancel}
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 52)
+	require.True(t, errors.Is(d.ResetAndShuffle(ctx), context.Canceled))
+	_, _, err := d.DrawCard(context.Background(), alice.ID())
+	require.True(t, errors.Is(err, deck.ErrDeckEmpty))
+	// A new hand supersedes the abandoned one
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+	// A canceled draw leaves the card on the deck
+	require.True(t, errors.Is(alice.DrawCard(ctx, d), context.Canceled))
+	require.Len(t, deckCards(t, d), 52)
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+	require.Len(t, deckCards(t, d), 51)
+
+	// A player canceled in stage 2 closes the hand so even the same hand can
+	// start over
+	handID := uuid.New()
+	cards := make([]*big.Int, 2)
+	for i := range cards {
+		cards[i], err = sra.P256.EncodeInt(big.NewInt(int64(i + 2)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, alice.ShuffleStage1(context.Background(), handID, cards))
+	require.True(t, errors.Is(alice.ShuffleStage2(ctx, cards), context.Canceled))
+	require.NoError(t, alice.ShuffleStage1(context.Background(), handID, cards))
+	require.True(t, errors.Is(alice.ShuffleStage1(context.Background(), handID, cards), deck.ErrStageOrder))
+	_, err = alice.ShuffleComplete(context.Background(), cards)
+	require.True(t, errors.Is(err, deck.ErrStageOrder))
+}
+
+// cancelingPlayer is a Player that calls cancel once its stage 1 is done.
+type cancelingPlayer struct {
+	deck.Player
+	cancel context.CancelFunc
+}
+
+func (c *cancelingPlayer) ShuffleStage1(ctx context.Context, handID uuid.UUID, cards []*big.Int) error {
+	defer c.cancel()
+	return c.Player.ShuffleStage1(ctx, handID, cards)
+}
+
+var errKeyGen = errors.New("Key generation failed")
+
+// failingBackend is a backend whose key generation fails once failAfter keys
+// have been generated, unless failAfter is negative.
+type failingBackend struct {
+	sra.Backend
+	failAfter int64
+	generated int64
+}
+
+func (f *failingBackend) GenerateCipher(ctx context.Context, rnd io.Reader) (sra.Cipher, error) {
+	if n := atomic.AddInt64(&f.generated, 1); f.failAfter >= 0 && n > f.failAfter {
+		return nil, errKeyGen
+	}
+	return f.Backend.GenerateCipher(ctx, rnd)
+}
+
+// TestKeyPool confirms players take keys from a pool and generate them inline
+// once it's empty
+func TestKeyPool(t *testing.T) {
+	// No keys can be generated after the pool's, so the shuffle only works
+	// from the pool
+	backend := &failingBackend{Backend: sra.P256, failAfter: 60}
+	pool, err := sra.NewKeyPool(backend, 60, 60)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.Len() == 60 }, 5*time.Second, time.Millisecond)
+	alice, bob := deck.NewMe(backend), deck.NewMe(sra.P256)
+	require.NoError(t, alice.SetPool(pool))
+	// Pools for another backend are rejected
+	require.Error(t, bob.SetPool(pool))
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+	// Once the pool is gone, it falls back to generating inline
+	pool.Close()
+	backend.failAfter = -1
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.ElementsMatch(t, allCards(), deckCards(t, d))
+}
+
+// completeRecorder is a Player that keeps a copy of the completed deck.
+type completeRecorder struct {
+	deck.Player
+	cards []*big.Int
+}
+
+func (c *completeRecorder) ShuffleComplete(ctx context.Context, cards []*big.Int) ([]*big.Int, error) {
+	c.cards = append([]*big.Int(nil), cards...)
+	return c.Player.ShuffleComplete(ctx, cards)
+}
+
+// TestPolicy confirms players only decrypt what their policy approves
+func TestPolicy(t *testing.T) {
+	alice, bob := deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	drawer := alice.ID()
+	var requests []*deck.DecryptRequest
+	for _, me := range []*deck.Me{alice, bob} {
+		me.Policy = deck.AllPolicies(
+			deck.OnlyDrawer(func() uuid.UUID { return drawer }),
+			deck.PolicyFunc(func(req *deck.DecryptRequest) error {
+				if req.Purpose == deck.PurposeEndOfGame && req.Phase != "end" {
+					return errors.New("Game not over")
+				}
+				requests = append(requests, req)
+				return nil
+			}),
+			deck.NeverTwice(),
+		)
+	}
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 10)
+	d.SetPhase("deal")
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+
+	// Only the drawer can draw and a refused draw leaves the card on the deck
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+	require.Equal(t, &deck.DecryptRequest{
+		RequesterID: alice.ID(),
+		HandID:      d.HandID(),
+		Card:        alice.OrigEncryptedCards[0],
+		Position:    9,
+		Purpose:     deck.PurposeDraw,
+		Phase:       "deal",
+	}, requests[0])
+	err := bob.DrawCard(context.Background(), d)
+	var playerErr *deck.PlayerError
+	require.True(t, errors.As(err, &playerErr))
+	require.Equal(t, alice.ID(), playerErr.PlayerID)
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	drawer = bob.ID()
+	require.NoError(t, bob.DrawCard(context.Background(), d))
+
+	// An invalid value is refused without counting against the card
+	card := d.CardsIn(deck.ZoneDeck, uuid.Nil)[0]
+	req := &deck.DecryptRequest{RequesterID: bob.ID(), HandID: d.HandID(), Card: card, Position: -1}
+	_, _, err = alice.DecryptCard(context.Background(), req, big.NewInt(0))
+	require.True(t, errors.Is(err, deck.ErrRefused))
+
+	// The rest of the deck is only revealed at the end and only once
+	_, err = d.RevealCards(context.Background())
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	d.SetPhase("end")
+	revealed, err := d.RevealCards(context.Background())
+	require.NoError(t, err)
+	require.Len(t, revealed, 8)
+	_, err = d.RevealCards(context.Background())
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	_, err = d.MostlyRevealCard(context.Background(), alice.OrigEncryptedCards[0], uuid.Nil)
+	require.True(t, errors.Is(err, deck.ErrRefused))
+}
+
+// TestRevealPublic confirms face-up cards are revealed to every player
+func TestRevealPublic(t *testing.T) {
+	alice, bob := deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	for _, me := range []*deck.Me{alice, bob} {
+		me.Policy = deck.AllPolicies(
+			deck.PolicyFunc(func(req *deck.DecryptRequest) error {
+				if req.Purpose == deck.PurposePublicReveal && req.Phase != "flop" {
+					return errors.New("No public cards yet")
+				}
+				return nil
+			}),
+			deck.NeverTwice(),
+		)
+	}
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+
+	// Refused until the flop and the card stays on the deck
+	_, err := d.RevealPublic(context.Background())
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	d.SetPhase("flop")
+	var flop []*big.Int
+	for i := 0; i < 3; i++ {
+		card, err := d.RevealPublic(context.Background())
+		require.NoError(t, err)
+		flop = append(flop, card)
+	}
+	require.Equal(t, flop, d.PublicCards())
+	require.Equal(t, flop, alice.PublicCards)
+	require.Equal(t, flop, bob.PublicCards)
+	require.Len(t, bob.PublicEncryptedCards, 3)
+	revealed, err := d.RevealCards(context.Background())
+	require.NoError(t, err)
+	require.Len(t, revealed, 48)
+	cards, err := CardsFromBigInts(append(append(revealed, flop...), alice.DecryptedCards...))
+	require.NoError(t, err)
+	require.ElementsMatch(t, allCards(), cards)
+
+	// Players reject cards they didn't reveal
+	req := &deck.DecryptRequest{HandID: d.HandID(), Card: alice.OrigEncryptedCards[0], Purpose: deck.PurposePublicReveal}
+	require.Error(t, bob.PublicCardRevealed(context.Background(), req, alice.DecryptedCards[0]))
+	// And a new hand clears the public cards
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.Empty(t, d.PublicCards())
+	require.Empty(t, alice.PublicCards)
+}
+
+// TestPositionalDraws confirms cards can be drawn from anywhere in the deck
+// and several at a time
+func TestPositionalDraws(t *testing.T) {
+	alice := deck.NewMe(sra.P256)
+	bob := &countingPlayer{Player: deck.NewMe(sra.P256)}
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	cards := deckCards(t, d)
+	bob.calls, bob.batchCalls = 0, 0
+
+	// Bottom, then the card that was at 11
+	req, mostlyDecryptedCard, err := d.DrawBottom(context.Background(), alice.ID())
+	require.NoError(t, err)
+	require.Equal(t, 0, req.Position)
+	require.NoError(t, alice.ReceiveCard(context.Background(), req, mostlyDecryptedCard))
+	req, mostlyDecryptedCard, err = d.DrawAt(context.Background(), 10, alice.ID())
+	require.NoError(t, err)
+	require.NoError(t, alice.ReceiveCard(context.Background(), req, mostlyDecryptedCard))
+	require.Equal(t, []Card{cards[0], cards[11]}, playerCards(t, alice))
+	_, _, err = d.DrawAt(context.Background(), 50, alice.ID())
+	require.Error(t, err)
+
+	// Five off the top in one call
+	require.NoError(t, alice.DrawCards(context.Background(), d, 5))
+	require.Equal(t, []Card{cards[51], cards[50], cards[49], cards[48], cards[47]}, playerCards(t, alice)[2:])
+	require.Equal(t, 2, bob.calls)
+	require.Equal(t, 1, bob.batchCalls)
+	require.Len(t, deckCards(t, d), 45)
+	_, _, err = d.DrawN(context.Background(), 46, alice.ID())
+	require.True(t, errors.Is(err, deck.ErrDeckEmpty))
+
+	// A bad value in a batch leaves every key unused
+	alice.RetireUsedKeys = true
+	top := d.CardsIn(deck.ZoneDeck, uuid.Nil)[:2]
+	reqs := []*deck.DecryptRequest{
+		{HandID: d.HandID(), Card: top[0], Position: -1},
+		{HandID: d.HandID(), Card: top[1], Position: -1},
+	}
+	_, _, err = alice.DecryptCards(context.Background(), reqs, []*big.Int{top[0], big.NewInt(0)})
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	alice.CardDrawn(context.Background(), reqs[0])
+	_, _, err = alice.DecryptCards(context.Background(), reqs[:1], top[:1])
+	require.NoError(t, err)
+	alice.CardDrawn(context.Background(), reqs[0])
+	_, _, err = alice.DecryptCards(context.Background(), reqs[:1], top[:1])
+	require.True(t, errors.Is(err, deck.ErrRefused))
+}
+
+// countingPlayer is a Player that counts its DecryptCard and DecryptCards
+// calls.
+type countingPlayer struct {
+	deck.Player
+	calls      int
+	batchCalls int
+}
+
+func (c *countingPlayer) DecryptCard(
+	ctx context.Context,
+	req *deck.DecryptRequest,
+	valToDecrypt *big.Int,
+) (*big.Int, *sra.DecryptProof, error) {
+	c.calls++
+	return c.Player.DecryptCard(ctx, req, valToDecrypt)
+}
+
+func (c *countingPlayer) DecryptCards(
+	ctx context.Context,
+	reqs []*deck.DecryptRequest,
+	valsToDecrypt []*big.Int,
+) ([]*big.Int, []*sra.DecryptProof, error) {
+	c.batchCalls++
+	return c.Player.DecryptCards(ctx, reqs, valsToDecrypt)
+}
+
+// TestZones confirms the deck tracks where every card went
+func TestZones(t *testing.T) {
+	alice, bob := deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 52)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.Len(t, d.CardsIn(deck.ZoneDeck, uuid.Nil), 52)
+
+	burned, err := d.Burn()
+	require.NoError(t, err)
+	require.NoError(t, alice.DrawCards(context.Background(), d, 3))
+	require.NoError(t, bob.DrawCard(context.Background(), d))
+	public, err := d.RevealPublic(context.Background())
+	require.NoError(t, err)
+	aliceCards := append([]*big.Int(nil), alice.OrigEncryptedCards...)
+	require.NoError(t, alice.Discard(d, aliceCards[0]))
+	require.NoError(t, alice.Play(d, aliceCards[1], true))
+	require.NoError(t, alice.Play(d, aliceCards[2], false))
+	require.Empty(t, alice.OrigEncryptedCards)
+	require.Empty(t, alice.DecryptedCards)
+
+	// Can't move cards that aren't in your hand
+	require.Error(t, bob.Discard(d, aliceCards[1]))
+	require.Error(t, alice.Discard(d, burned))
+	require.True(t, errors.Is(d.Discard(alice.ID(), big.NewInt(2)), deck.ErrUnknownCard))
+
+	loc, ok := d.Location(burned)
+	require.True(t, ok)
+	require.Equal(t, deck.CardLocation{Zone: deck.ZoneBurn}, loc)
+	require.Equal(t, []*big.Int{aliceCards[0]}, d.CardsIn(deck.ZoneDiscard, alice.ID()))
+	require.Equal(t, []*big.Int{aliceCards[1]}, d.CardsIn(deck.ZoneTableFaceUp, alice.ID()))
+	require.Equal(t, []*big.Int{aliceCards[2]}, d.CardsIn(deck.ZoneTableFaceDown, alice.ID()))
+	require.Equal(t, bob.OrigEncryptedCards, d.CardsIn(deck.ZoneHand, bob.ID()))
+	faceUp := d.CardsIn(deck.ZoneTableFaceUp, uuid.Nil)
+	require.Len(t, faceUp, 1)
+	require.Equal(t, alice.PublicEncryptedCards, faceUp)
+	require.Equal(t, []*big.Int{public}, alice.PublicCards)
+	require.Len(t, d.CardsIn(deck.ZoneDeck, uuid.Nil), 46)
+	_, ok = d.Location(big.NewInt(2))
+	require.False(t, ok)
+
+	// Cards can only be drawn for players, face-up cards are revealed publicly
+	for _, id := range []uuid.UUID{uuid.Nil, uuid.New()} {
+		_, _, err := d.DrawCard(context.Background(), id)
+		require.Error(t, err)
+		_, _, err = d.DrawN(context.Background(), 2, id)
+		require.Error(t, err)
+	}
+	require.Len(t, d.CardsIn(deck.ZoneDeck, uuid.Nil), 46)
+	require.Empty(t, d.CardsIn(deck.ZoneHand, uuid.Nil))
+}
+
+// TestReshuffleSubset confirms discards can be shuffled back into the deck
+// without affecting cards in hands
+func TestReshuffleSubset(t *testing.T) {
+	secret := make([]byte, deck.MinMasterSecretSize)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	alice := deck.NewMe(sra.P256)
+	bob, err := deck.NewMeWithSecret(sra.P256, uuid.New(), secret)
+	require.NoError(t, err)
+	// Bob decrypts alice's discards for her draws, which doesn't stop them
+	// being reshuffled
+	bob.Policy = deck.NeverTwice()
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 20)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.NoError(t, alice.DrawCards(context.Background(), d, 3))
+	require.NoError(t, bob.DrawCards(context.Background(), d, 2))
+	discarded := append([]*big.Int(nil), alice.OrigEncryptedCards[:2]...)
+	for _, card := range discarded {
+		require.NoError(t, alice.Discard(d, card))
+	}
+	burned, err := d.Burn()
+	require.NoError(t, err)
+
+	// Hands can't be reshuffled and a failed reshuffle changes nothing
+	require.Error(t, d.ReshuffleSubset(context.Background(), bob.OrigEncryptedCards))
+	subset := append(d.CardsIn(deck.ZoneDeck, uuid.Nil), append(discarded, burned)...)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.True(t, errors.Is(d.ReshuffleSubset(ctx, subset), context.Canceled))
+	require.Len(t, deckCards(t, d), 14)
+
+	// Twice, so keys derived from bob's secret have to differ per reshuffle
+	for i := 0; i < 2; i++ {
+		require.NoError(t, d.ReshuffleSubset(context.Background(), subset))
+		subset = d.CardsIn(deck.ZoneDeck, uuid.Nil)
+		require.Len(t, subset, 17)
+	}
+	for _, card := range append(discarded, burned) {
+		_, ok := d.Location(card)
+		require.False(t, ok)
+	}
+	// Every card is still somewhere and hands still decrypt, which decrypts
+	// cards more than once
+	bob.Policy = nil
+	cards := append(deckCards(t, d), append(playerCards(t, alice), playerCards(t, bob)...)...)
+	require.ElementsMatch(t, allCards()[:20], cards)
+	for i, card := range bob.OrigEncryptedCards {
+		revealed, err := d.MostlyRevealCard(context.Background(), card, uuid.Nil)
+		require.NoError(t, err)
+		revealed, err = sra.P256.DecodeInt(revealed)
+		require.NoError(t, err)
+		require.Zero(t, bob.DecryptedCards[i].Cmp(revealed))
+	}
+	// Drawing the reshuffled cards works and the replaced keys can be disclosed
+	require.NoError(t, alice.DrawCards(context.Background(), d, 17))
+	require.ElementsMatch(t, allCards()[:20], append(playerCards(t, alice), playerCards(t, bob)...))
+	disclosure := bob.Disclosure()
+	require.Len(t, disclosure.Keys, 2*17)
+	require.NoError(t, d.VerifyDisclosure(disclosure))
+}
+
+// TestReshuffleRefusesHandCards confirms a deck that skips its zone check
+// can't have a player rekey a card in its hand or one its policy refuses
+func TestReshuffleRefusesHandCards(t *testing.T) {
+	alice, bob := deck.NewMe(sra.P256), deck.NewMe(sra.P256)
+	var asked []*deck.DecryptRequest
+	bob.Policy = deck.PolicyFunc(func(req *deck.DecryptRequest) error {
+		asked = append(asked, req)
+		if req.Purpose == deck.PurposeReshuffle && req.Phase == "no reshuffles" {
+			return errors.New("No reshuffles now")
+		}
+		return nil
+	})
+	d := deck.New(sra.P256, []deck.Player{alice, bob}, 10)
+	require.NoError(t, d.ResetAndShuffle(context.Background()))
+	require.NoError(t, bob.DrawCard(context.Background(), d))
+	require.NoError(t, alice.DrawCard(context.Background(), d))
+	hand := bob.OrigEncryptedCards[0]
+	reshuffle := func(card *big.Int) *deck.DecryptRequest {
+		return &deck.DecryptRequest{HandID: d.HandID(), Card: card, Position: -1, Purpose: deck.PurposeReshuffle}
+	}
+
+	// Bob refuses his own card however it is asked for, before his policy
+	asked = nil
+	for _, req := range []*deck.DecryptRequest{
+		reshuffle(hand),
+		{HandID: d.HandID(), Card: hand, Position: -1, Purpose: deck.PurposeEndOfGame},
+	} {
+		err := bob.ReshuffleStart(context.Background(), []*deck.DecryptRequest{req}, []*big.Int{hand})
+		require.True(t, errors.Is(err, deck.ErrRefused))
+	}
+	require.Empty(t, asked)
+	// Alice's card goes to bob's policy as a reshuffle
+	aliceCard := alice.OrigEncryptedCards[0]
+	require.NoError(t, bob.ReshuffleStart(context.Background(),
+		[]*deck.DecryptRequest{reshuffle(aliceCard)}, []*big.Int{new(big.Int).Set(aliceCard)}))
+	require.Len(t, asked, 1)
+	require.Equal(t, deck.PurposeReshuffle, asked[0].Purpose)
+	req := reshuffle(aliceCard)
+	req.Phase = "no reshuffles"
+	err := bob.ReshuffleStart(context.Background(), []*deck.DecryptRequest{req}, []*big.Int{new(big.Int).Set(aliceCard)})
+	require.True(t, errors.Is(err, deck.ErrRefused))
+
+	// The deck's own reshuffle still asks with the phase and leaves the hands
+	// alone
+	d.SetPhase("no reshuffles")
+	err = d.ReshuffleSubset(context.Background(), d.CardsIn(deck.ZoneDeck, uuid.Nil))
+	var playerErr *deck.PlayerError
+	require.True(t, errors.As(err, &playerErr))
+	require.Equal(t, bob.ID(), playerErr.PlayerID)
+	require.True(t, errors.Is(err, deck.ErrRefused))
+	require.Len(t, playerCards(t, bob), 1)
+	require.Len(t, playerCards(t, alice), 1)
+}
+
+// TestLyingPlayer confirms a player returning a bad decryption is caught and
+// named
+func TestLyingPlayer(t *testing.T) {
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.MODP2048}, sra.P256} {
+		alice, bob := deck.NewMe(backend), deck.NewMe(backend)
+		liar := &lyingPlayer{Player: deck.NewMe(backend)}
+		d := deck.New(backend, []deck.Player{alice, bob, liar}, 10)
+		require.NoError(t, d.ResetAndShuffle(context.Background()))
+		require.NoError(t, alice.DrawCard(context.Background(), d))
+		liar.lie = true
+		err := alice.DrawCard(context.Background(), d)
+		var playerErr *deck.PlayerError
+		require.True(t, errors.As(err, &playerErr))
+		require.Equal(t, liar.ID(), playerErr.PlayerID)
+		require.Contains(t, err.Error(), liar.ID().String())
+	}
+}
+
+// lyingPlayer is a Player that, when lie is set, returns the value it was
+// asked to decrypt instead of decrypting it.
+type lyingPlayer struct {
+	deck.Player
+	lie bool
+}
+
+func (l *lyingPlayer) DecryptCard(
+	ctx context.Context,
+	req *deck.DecryptRequest,
+	valToDecrypt *big.Int,
+) (*big.Int, *sra.DecryptProof, error) {
+	decrypted, proof, err := l.Player.DecryptCard(ctx, req, valToDecrypt)
+	if l.lie {
+		return valToDecrypt, proof, err
+	}
+	return decrypted, proof, err
+}
+
 type Card struct {
 	// 0 through 3
 	Suit int
@@ -118,8 +963,16 @@ func (c Card) String() string {
 	return string(c.CardName()) + string(c.SuitChar())
 }
 
+func allCards() []Card {
+	cards := make([]Card, 52)
+	for i := 0; i < 52; i++ {
+		cards[i] = CardFromInt(i)
+	}
+	return cards
+}
+
 func deckCards(t *testing.T, d *deck.Deck) []Card {
-	revealed, err := d.RevealCards()
+	revealed, err := d.RevealCards(context.Background())
 	require.NoError(t, err)
 	cards, err := CardsFromBigInts(revealed)
 	require.NoError(t, err)
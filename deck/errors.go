@@ -0,0 +1,36 @@
+package deck
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownCard is returned when a card is not from the current shuffle
+	// (or the player has no key for it). Asking again won't help.
+	ErrUnknownCard = errors.New("Unknown card")
+	// ErrRefused is returned when a player won't decrypt a card it has a key for
+	// (e.g. the key was already used or the value to decrypt is invalid).
+	ErrRefused = errors.New("Decryption refused")
+	// ErrStageOrder is returned when shuffle stages are called out of order. The
+	// shuffle has to be started over.
+	ErrStageOrder = errors.New("Shuffle stage out of order")
+	// ErrDeckEmpty is returned when drawing from a deck with no cards left,
+	// including one that was never shuffled or whose shuffle failed.
+	ErrDeckEmpty = errors.New("Deck is empty")
+)
+
+// PlayerError is returned by Deck when a call to a player failed or the player
+// misbehaved (e.g. gave a bad decryption proof), so the player can be blamed.
+// The underlying error, possibly one of the errors above or a context error,
+// is available via errors.Is and errors.As.
+type PlayerError struct {
+	PlayerID uuid.UUID
+	Err      error
+}
+
+func (p *PlayerError) Error() string { return fmt.Sprintf("Player %v failed: %v", p.PlayerID, p.Err) }
+
+func (p *PlayerError) Unwrap() error { return p.Err }
@@ -1,7 +1,9 @@
 package deck
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"math/big"
 
@@ -11,54 +13,158 @@ import (
 )
 
 // Player is an interface implemented by all players. This means it could be
-// implemented with a remote player or a local one.
+// implemented with a remote player or a local one. Every call takes a context
+// for deadlines and cancellation. A player whose shuffle was abandoned part way
+// (e.g. on cancellation) must accept the next ShuffleStage1 for a new hand ID.
 type Player interface {
 	// ID is the unique identifier for this player.
 	ID() uuid.UUID
 
 	// ShuffleStage1 encrypts all cards with a single encryption key, stores
 	// that key for stage 2, and shuffles the slice. The cards may be encrypted
-	// from another player's stage-1 run or not.
-	ShuffleStage1(cards []*big.Int) error
+	// from another player's stage-1 run or not. The handID is unique to this
+	// shuffle and is the same for every player.
+	ShuffleStage1(ctx context.Context, handID uuid.UUID, cards []*big.Int) error
 
 	// ShuffleStage2 decrypts each card from stage 1, then re-encrypts it with
 	// a new per-card key, and stores that key by index for use on complete.
 	// The cards may be encrypted from another player's stage-2 run or not.
-	ShuffleStage2(cards []*big.Int) error
+	ShuffleStage2(ctx context.Context, cards []*big.Int) error
 
 	// ShuffleComplete provides the completed, fully encrypted deck after all
 	// players' stage-2 runs are done. It is in the same order as stage 2 and
 	// the per-card keys from stage 2 can now be mapped to the fully-encrypted
-	// card values.
-	ShuffleComplete(cards []*big.Int) error
+	// card values. The result is the commitment (see sra.Commit) to the key for
+	// each card, in the same order, which binds the player to their keys before
+	// any card is drawn.
+	ShuffleComplete(ctx context.Context, cards []*big.Int) ([]*big.Int, error)
 
-	// DecryptCard locates the decryption key for origEncryptionCard and
+	// DecryptCard locates the decryption key for the request's card and
 	// returns valToDecrypt decrypted with it. The valToDecrypt value may be
-	// some already-half-decrypted value from other players.
-	DecryptCard(origEncryptedCard *big.Int, valToDecrypt *big.Int) *big.Int
+	// some already-half-decrypted value from other players. If the backend has
+	// a prover, the result also has a proof that it was decrypted with the key
+	// behind the card's commitment. If there is no key for the card, the error
+	// is ErrUnknownCard and if the player won't decrypt it for the request, it
+	// is ErrRefused.
+	DecryptCard(ctx context.Context, req *DecryptRequest, valToDecrypt *big.Int) (*big.Int, *sra.DecryptProof, error)
+
+	// DecryptCards is DecryptCard for several requests at once so they can be
+	// done in a single call (e.g. a single round trip to a remote player). The
+	// results are in request order and proofs may be nil if the backend has no
+	// prover.
+	DecryptCards(
+		ctx context.Context,
+		reqs []*DecryptRequest,
+		valsToDecrypt []*big.Int,
+	) ([]*big.Int, []*sra.DecryptProof, error)
+
+	// PublicCardRevealed is told the plain card value once the card of a
+	// PurposePublicReveal request has been decrypted by every player. The
+	// player may reject a card it didn't take part in revealing.
+	PublicCardRevealed(ctx context.Context, req *DecryptRequest, card *big.Int) error
+
+	// CardDrawn is told once every player but the requester has decrypted the
+	// card of a PurposeDraw request and the card has been taken off the deck,
+	// so only the requester's own decryption is left. It isn't told to the
+	// requester. The player should ignore a card it didn't decrypt for the
+	// draw.
+	CardDrawn(ctx context.Context, req *DecryptRequest)
+
+	// ReshuffleStart starts reshuffling some of the fully-encrypted cards of
+	// the hand back into the deck. Without reordering them, each card's value
+	// in cards has this player's per-card key replaced with a single reshuffle
+	// key, so once every player has done this, every card is encrypted with one
+	// key per player like after stage 1. There is a PurposeReshuffle request
+	// for each of the cards being reshuffled, in the same order, so the
+	// per-card keys can be found and the player can refuse cards it still
+	// needs (e.g. ones in its hand).
+	ReshuffleStart(ctx context.Context, reqs []*DecryptRequest, cards []*big.Int) error
+
+	// ReshuffleMix replaces the reshuffle key from ReshuffleStart on every card
+	// with a new key, stores that key for stage 2, and shuffles the slice like
+	// ShuffleStage1. The reshuffle then continues with ShuffleStage2 and
+	// ShuffleComplete which add the new cards to the hand, keeping the keys of
+	// every other card.
+	ReshuffleMix(ctx context.Context, cards []*big.Int) error
 }
 
 // Me is an implementation of Player for a local user.
 type Me struct {
-	id          uuid.UUID
-	sharedPrime *big.Int
-	keyBits     int
+	id      uuid.UUID
+	backend sra.Backend
+	// Only non-nil for players created with NewMeWithSecret
+	masterSecret []byte
+	// Set on stage 1 or RestoreHand
+	handID uuid.UUID
+	// Random bytes mixed into derived keys, set with handID for players with a
+	// master secret. This keeps a reused hand ID from giving the same keys.
+	handSalt []byte
 	// Only non-nil after stage 1 and before stage 2
-	tempShuffleStage1Pair *sra.KeyPair
+	tempShuffleStage1Key sra.Cipher
 	// Only non-nil after stage 2 and before complete
-	tempShuffleStage2Pairs []*sra.KeyPair
+	tempShuffleStage2Keys []sra.Cipher
+	// Only non-nil after reshuffle start and before reshuffle mix
+	tempReshuffleKey sra.Cipher
+	// Only non-nil during a reshuffle. The encrypted card strings being
+	// replaced.
+	reshuffleCards []string
+	// Number of reshuffles this hand, used to derive distinct keys
+	reshuffles int
 	// Only non-nil on complete. Keyed by the encrypted card string.
-	cardKeys map[string]*sra.KeyPair
+	cardKeys map[string]sra.Cipher
+	// Keys no longer usable for DecryptCard but kept for disclosure until the
+	// hand is closed. Keyed by the encrypted card string.
+	retiredKeys map[string]sra.Cipher
 	// DecryptedCards are the current, decrypted cards in my hand.
 	DecryptedCards []*big.Int
 	// OrigEncryptedCards are the fully-encrypted values for DecryptedCards.
 	OrigEncryptedCards []*big.Int
+	// PublicCards are the current hand's cards revealed to every player, in
+	// reveal order.
+	PublicCards []*big.Int
+	// PublicEncryptedCards are the fully-encrypted values for PublicCards.
+	PublicEncryptedCards []*big.Int
+	// Fully-encrypted card strings I decrypted for a public reveal this hand
+	publicReveals map[string]bool
+	// Fully-encrypted card strings I decrypted for another player's draw this
+	// hand that wasn't done yet
+	drawDecrypts map[string]bool
+	// Workers is the number of goroutines used to generate per-card keys and to
+	// encrypt and decrypt the deck during shuffles. If less than 1, GOMAXPROCS
+	// is used. Keys are always in card order regardless of this.
+	Workers int
+	// RetireUsedKeys, if true, retires each per-card key once the card has
+	// been fully revealed since every player's decryption is only needed once.
+	// That is when CardDrawn says another player's draw is done, when
+	// ReceiveCard finishes my own draw, or when PublicCardRevealed is told the
+	// value. A failed draw or reveal leaves the key so it can be retried, and
+	// keys used for end-of-game reveals are kept. Retired keys are only
+	// available from Disclosure. This is not the default since the debugging
+	// reveals on Deck need to decrypt cards more than once.
+	RetireUsedKeys bool
+	// Set with SetPool
+	pool *sra.KeyPool
+	// Policy, if set, approves or refuses every DecryptCard request, including
+	// my own when drawing. If nil, every request for a card I have a key for is
+	// approved, which lets anyone see the whole deck and is only meant for
+	// debugging.
+	Policy Policy
+}
+
+// Disclosure is the bundle of per-card keys a player gives up at the end of a
+// hand so others can verify the revealed cards were handled properly.
+type Disclosure struct {
+	PlayerID uuid.UUID
+	HandID   uuid.UUID
+	// Keys are keyed by the fully-encrypted card string.
+	Keys map[string]sra.Cipher
 }
 
-// NewMe creates a new local player with the given sharedPrime and keyBits
-// count used to create the SRA key pair. This is assigned a random UUID ID.
-func NewMe(sharedPrime *big.Int, keyBits int) *Me {
-	ret := &Me{sharedPrime: sharedPrime, keyBits: keyBits}
+// NewMe creates a new local player with the given shared backend used to
+// create the commutative keys (e.g. a sra.SRABackend or sra.P256). This is
+// assigned a random UUID ID.
+func NewMe(backend sra.Backend) *Me {
+	ret := &Me{backend: backend}
 	var err error
 	if ret.id, err = uuid.NewRandom(); err != nil {
 		panic(err)
@@ -66,93 +172,627 @@ func NewMe(sharedPrime *big.Int, keyBits int) *Me {
 	return ret
 }
 
+// MinMasterSecretSize is the minimum size in bytes of the secret given to
+// NewMeWithSecret.
+const MinMasterSecretSize = 32
+
+// HandSaltSize is the size in bytes of HandSalt.
+const HandSaltSize = 32
+
+// NewMeWithSecret creates a new local player like NewMe except with the given
+// ID and with every key derived from secret, the hand ID, a random salt chosen
+// at the start of each hand, and the card index instead of generated randomly.
+// This means only the secret and each hand's HandSalt have to be backed up and
+// the keys for a hand can be rebuilt with RestoreHand. The secret must be at
+// least MinMasterSecretSize random bytes and must never be shared.
+func NewMeWithSecret(backend sra.Backend, id uuid.UUID, secret []byte) (*Me, error) {
+	if len(secret) < MinMasterSecretSize {
+		return nil, fmt.Errorf("Master secret too short")
+	}
+	return &Me{id: id, backend: backend, masterSecret: append([]byte(nil), secret...)}, nil
+}
+
+// Labels for the key derivation info so stage 1 and card keys never collide.
+const (
+	keyLabelStage1    byte = 1
+	keyLabelCard      byte = 2
+	keyLabelReshuffle byte = 3
+)
+
+// newKey creates a key for the current hand. With a master secret it is derived
+// from the hand ID, hand salt, label, index, and (after the first reshuffle)
+// the reshuffle count, otherwise it is random and taken from the pool if there
+// is one.
+func (m *Me) newKey(ctx context.Context, label byte, index int) (sra.Cipher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.masterSecret == nil {
+		if m.pool != nil {
+			if key, ok := m.pool.Take(); ok {
+				return key, nil
+			}
+		}
+		return m.backend.GenerateCipher(ctx, rand.Reader)
+	}
+	info := append(append(m.handID[:], m.handSalt...), label)
+	info = binary.BigEndian.AppendUint32(info, uint32(index))
+	if m.reshuffles > 0 {
+		info = binary.BigEndian.AppendUint32(info, uint32(m.reshuffles))
+	}
+	return sra.DeriveCipher(ctx, m.backend, m.masterSecret, info)
+}
+
+// SetPool sets the pool new keys are taken from before generating them inline,
+// or stops using one if pool is nil. The pool must be for the same backend as
+// mine and can't be used by players with a master secret since their keys are
+// derived. The pool is not owned by the player and must be closed by the
+// caller.
+func (m *Me) SetPool(pool *sra.KeyPool) error {
+	if pool != nil {
+		if m.masterSecret != nil {
+			return fmt.Errorf("Players with a master secret can't use a pool")
+		} else if pool.Backend() != m.backend {
+			return fmt.Errorf("Pool is for a different backend")
+		}
+	}
+	m.pool = pool
+	return nil
+}
+
 // ID impls Player.ID.
 func (m *Me) ID() uuid.UUID { return m.id }
 
-// ShuffleStage1 impls Player.ShuffleStage1.
-func (m *Me) ShuffleStage1(cards []*big.Int) (err error) {
-	if m.tempShuffleStage1Pair != nil || m.tempShuffleStage2Pairs != nil {
-		return fmt.Errorf("Another stage was left incomplete")
+// ShuffleStage1 impls Player.ShuffleStage1. A new hand ID drops whatever was
+// left of the previous hand, including an incomplete shuffle. On failure, the
+// hand is closed.
+func (m *Me) ShuffleStage1(ctx context.Context, handID uuid.UUID, cards []*big.Int) (err error) {
+	if (m.tempShuffleStage1Key != nil || m.tempShuffleStage2Keys != nil || m.tempReshuffleKey != nil) &&
+		handID == m.handID {
+		return fmt.Errorf("Another stage was left incomplete: %w", ErrStageOrder)
 	}
-	m.cardKeys = nil
-	m.DecryptedCards = nil
-	m.OrigEncryptedCards = nil
-	// Create a key pair for the entire deck
-	if m.tempShuffleStage1Pair, err = sra.GenerateKeyPair(rand.Reader, m.sharedPrime, m.keyBits); err != nil {
+	m.CloseHand()
+	m.handID = handID
+	defer func() {
+		if err != nil {
+			m.CloseHand()
+		}
+	}()
+	// The hand ID is chosen by whoever runs the deck, so fresh randomness is
+	// what keeps a replayed hand ID from getting the keys of an old hand
+	if m.masterSecret != nil {
+		m.handSalt = make([]byte, HandSaltSize)
+		if _, err = rand.Read(m.handSalt); err != nil {
+			return
+		}
+	}
+	// Create a key for the entire deck
+	key, err := m.newKey(ctx, keyLabelStage1, 0)
+	if err != nil {
 		return
 	}
+	m.tempShuffleStage1Key = key
 	// Encrypt each card
-	for i, card := range cards {
-		cards[i] = m.tempShuffleStage1Pair.EncryptInt(card)
+	if err = sra.EncryptInts(m.tempShuffleStage1Key, cards, m.Workers); err != nil {
+		return
+	} else if err = ctx.Err(); err != nil {
+		return
 	}
 	// Shuffle em
 	newCryptoRand().Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
 	return
 }
 
-// ShuffleStage2 impls Player.ShuffleStage2.
-func (m *Me) ShuffleStage2(cards []*big.Int) (err error) {
+// ShuffleStage2 impls Player.ShuffleStage2. On failure, the hand is closed or,
+// during a reshuffle, just the reshuffle is dropped.
+func (m *Me) ShuffleStage2(ctx context.Context, cards []*big.Int) (err error) {
 	// TODO: Could check things like count and what not here
-	if m.tempShuffleStage1Pair == nil || m.tempShuffleStage2Pairs != nil || m.cardKeys != nil {
-		return fmt.Errorf("Stage 1 not complete")
+	if m.tempShuffleStage1Key == nil || m.tempShuffleStage2Keys != nil || (m.cardKeys != nil && m.reshuffleCards == nil) {
+		return fmt.Errorf("Stage 1 not complete: %w", ErrStageOrder)
 	}
-	m.tempShuffleStage2Pairs = make([]*sra.KeyPair, len(cards))
-	for i, card := range cards {
-		// Generate key pair for just this card
-		if m.tempShuffleStage2Pairs[i], err = sra.GenerateKeyPair(rand.Reader, m.sharedPrime, m.keyBits); err != nil {
-			break
+	// Generate a key for each card
+	if m.tempShuffleStage2Keys, err = m.newCardKeys(ctx, len(cards)); err == nil {
+		// Decrypt what we had before and re-encrypt with card-specific keys
+		if err = sra.ReencryptInts(m.tempShuffleStage1Key, m.tempShuffleStage2Keys, cards, m.Workers); err == nil {
+			err = ctx.Err()
 		}
-		// Decrypt what we had before and re-encrypt with card-specific key pair
-		cards[i] = m.tempShuffleStage2Pairs[i].EncryptInt(m.tempShuffleStage1Pair.DecryptInt(card))
 	}
-	m.tempShuffleStage1Pair = nil
-	return
+	// On failure, drop the whole hand so a new shuffle can start
+	if err != nil {
+		m.failShuffle()
+		return err
+	}
+	m.tempShuffleStage1Key.Destroy()
+	m.tempShuffleStage1Key = nil
+	return nil
 }
 
-// ShuffleComplete impls Player.ShuffleComplete.
-func (m *Me) ShuffleComplete(cards []*big.Int) error {
-	if m.tempShuffleStage1Pair != nil || len(m.tempShuffleStage2Pairs) != len(cards) || m.cardKeys != nil {
-		return fmt.Errorf("Stage 2 not complete")
+// newCardKeys creates count per-card keys, in card order, across Workers
+// goroutines with sra.ParallelFor. Once a key fails or ctx is done, no more are
+// started. On failure, every error is returned joined and the keys that were
+// created are destroyed.
+func (m *Me) newCardKeys(ctx context.Context, count int) ([]sra.Cipher, error) {
+	keys := make([]sra.Cipher, count)
+	err := sra.ParallelFor(count, m.Workers, func(i int) (err error) {
+		if keys[i], err = m.newKey(ctx, keyLabelCard, i); err != nil {
+			return fmt.Errorf("Key for card %v: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		for _, key := range keys {
+			if key != nil {
+				key.Destroy()
+			}
+		}
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ShuffleComplete impls Player.ShuffleComplete. If ctx is done or the keys
+// can't be committed to, the hand is closed or, during a reshuffle, just the
+// reshuffle is dropped. Completing a reshuffle retires the keys of the cards
+// that were replaced.
+func (m *Me) ShuffleComplete(ctx context.Context, cards []*big.Int) (commitments []*big.Int, err error) {
+	if m.tempShuffleStage1Key != nil || len(m.tempShuffleStage2Keys) != len(cards) ||
+		(m.cardKeys != nil && m.reshuffleCards == nil) {
+		return nil, fmt.Errorf("Stage 2 not complete: %w", ErrStageOrder)
+	}
+	// Commit to every key so decryptions and disclosures can be checked
+	if err = ctx.Err(); err == nil {
+		commitments, err = sra.CommitAll(m.backend, m.tempShuffleStage2Keys, m.Workers)
+	}
+	if err != nil {
+		m.failShuffle()
+		return nil, err
+	}
+	// Retire the replaced keys so they're only available for disclosure
+	if m.reshuffleCards != nil {
+		if m.retiredKeys == nil {
+			m.retiredKeys = map[string]sra.Cipher{}
+		}
+		for _, card := range m.reshuffleCards {
+			if key := m.cardKeys[card]; key != nil {
+				m.retiredKeys[card] = key
+				delete(m.cardKeys, card)
+			}
+		}
+		m.reshuffleCards = nil
 	}
 	// Just map the cards to their keys
-	m.cardKeys = make(map[string]*sra.KeyPair, len(cards))
+	if m.cardKeys == nil {
+		m.cardKeys = make(map[string]sra.Cipher, len(cards))
+	}
 	for i, card := range cards {
-		m.cardKeys[card.String()] = m.tempShuffleStage2Pairs[i]
+		m.cardKeys[card.String()] = m.tempShuffleStage2Keys[i]
 	}
-	m.tempShuffleStage2Pairs = nil
-	return nil
+	m.tempShuffleStage2Keys = nil
+	return commitments, nil
 }
 
-// DecryptCard impls Player.DecryptCard.
-func (m *Me) DecryptCard(origEncryptedCard *big.Int, valToDecrypt *big.Int) *big.Int {
-	// TODO: In a real implementation, this player would have for more
-	// information to make sure they are ok with giving this up in this
-	// situation (e.g. info could include the player asking or whether it was
-	// their turn).
-	// TODO: Also note, we'd probably remove the key once its used in
-	// real-world circumstances, especially in games where discarded cards can
-	// be reused.
-	cardPair := m.cardKeys[origEncryptedCard.String()]
-	if cardPair == nil {
+// HandSalt returns the random salt of the current hand that RestoreHand needs
+// along with the master secret. It is nil for players without a master secret.
+// It is not secret by itself but should be backed up with the secret.
+func (m *Me) HandSalt() []byte {
+	if m.handSalt == nil {
 		return nil
 	}
-	return cardPair.DecryptInt(valToDecrypt)
+	return append([]byte(nil), m.handSalt...)
+}
+
+// RestoreHand rebuilds the per-card keys for handID from the master secret and
+// the hand's HandSalt given the completed deck as it was sent to
+// ShuffleComplete. This is only possible for players created with
+// NewMeWithSecret. The cards in my hand and the cards from any reshuffle are
+// not restored.
+func (m *Me) RestoreHand(handID uuid.UUID, handSalt []byte, cards []*big.Int) error {
+	if m.masterSecret == nil {
+		return fmt.Errorf("No master secret")
+	} else if len(handSalt) != HandSaltSize {
+		return fmt.Errorf("Hand salt must be %v bytes", HandSaltSize)
+	}
+	m.CloseHand()
+	m.handID = handID
+	m.handSalt = append([]byte(nil), handSalt...)
+	m.cardKeys = make(map[string]sra.Cipher, len(cards))
+	for i, card := range cards {
+		key, err := m.newKey(context.Background(), keyLabelCard, i)
+		if err != nil {
+			m.CloseHand()
+			return err
+		}
+		m.cardKeys[card.String()] = key
+	}
+	return nil
+}
+
+// CardKey returns my unretired key for the fully-encrypted card or nil if I
+// don't have one or it was retired. This is meant for disclosing single keys
+// (e.g. at the end of a game so other players can verify a card) without
+// giving up any others. Retired keys are only available from Disclosure.
+func (m *Me) CardKey(origEncryptedCard *big.Int) sra.Cipher {
+	return m.cardKeys[origEncryptedCard.String()]
+}
+
+// anyCardKey returns my key for the fully-encrypted card, retired or not, or
+// nil if I don't have one.
+func (m *Me) anyCardKey(origEncryptedCard *big.Int) sra.Cipher {
+	if key := m.cardKeys[origEncryptedCard.String()]; key != nil {
+		return key
+	}
+	return m.retiredKeys[origEncryptedCard.String()]
+}
+
+// Disclosure returns the retired keys of the current hand, i.e. the keys for
+// every card that has been revealed when RetireUsedKeys is set. The keys are
+// destroyed by CloseHand so they must be sent or encoded before then.
+func (m *Me) Disclosure() *Disclosure {
+	ret := &Disclosure{PlayerID: m.id, HandID: m.handID, Keys: make(map[string]sra.Cipher, len(m.retiredKeys))}
+	for card, key := range m.retiredKeys {
+		ret.Keys[card] = key
+	}
+	return ret
+}
+
+// CloseHand destroys every key of the current hand, including retired ones,
+// and empties my hand and the public cards. This is done automatically at the
+// start of the next shuffle.
+func (m *Me) CloseHand() {
+	m.dropShuffle()
+	for _, keys := range []map[string]sra.Cipher{m.cardKeys, m.retiredKeys} {
+		for _, key := range keys {
+			key.Destroy()
+		}
+	}
+	m.reshuffles = 0
+	m.handSalt = nil
+	m.cardKeys = nil
+	m.retiredKeys = nil
+	m.DecryptedCards = nil
+	m.OrigEncryptedCards = nil
+	m.PublicCards = nil
+	m.PublicEncryptedCards = nil
+	m.publicReveals = nil
+	m.drawDecrypts = nil
+}
+
+// dropShuffle destroys the keys of an incomplete shuffle or reshuffle, keeping
+// the keys of completed cards.
+func (m *Me) dropShuffle() {
+	for _, key := range append([]sra.Cipher{m.tempShuffleStage1Key, m.tempReshuffleKey}, m.tempShuffleStage2Keys...) {
+		if key != nil {
+			key.Destroy()
+		}
+	}
+	m.tempShuffleStage1Key = nil
+	m.tempShuffleStage2Keys = nil
+	m.tempReshuffleKey = nil
+	m.reshuffleCards = nil
+}
+
+// failShuffle cleans up after a failed shuffle stage. A failed reshuffle only
+// drops the reshuffle, anything else closes the hand.
+func (m *Me) failShuffle() {
+	if m.reshuffleCards != nil {
+		m.dropShuffle()
+	} else {
+		m.CloseHand()
+	}
+}
+
+// ReshuffleStart impls Player.ReshuffleStart. Every card must be from the
+// current hand. Cards in my hand and requests for another purpose are
+// ErrRefused, and every request is given to Policy once all of them have been
+// checked. The keys of the cards are kept until the reshuffle completes, so if
+// it fails they still work. A new reshuffle drops an incomplete one.
+func (m *Me) ReshuffleStart(
+	ctx context.Context,
+	reqs []*DecryptRequest,
+	cards []*big.Int,
+) (err error) {
+	if m.cardKeys == nil {
+		return fmt.Errorf("No completed shuffle for hand: %w", ErrStageOrder)
+	} else if len(reqs) != len(cards) {
+		return fmt.Errorf("Have %v cards for %v requests", len(cards), len(reqs))
+	}
+	m.dropShuffle()
+	inHand := make(map[string]bool, len(m.OrigEncryptedCards))
+	for _, card := range m.OrigEncryptedCards {
+		inHand[card.String()] = true
+	}
+	keys := make([]sra.Cipher, len(cards))
+	reshuffleCards := make([]string, len(cards))
+	seen := make(map[string]bool, len(cards))
+	for i, req := range reqs {
+		card := req.Card.String()
+		if req.HandID != m.handID {
+			return fmt.Errorf("Card %v is from another hand: %w", i, ErrUnknownCard)
+		} else if req.Purpose != PurposeReshuffle {
+			return fmt.Errorf("Card %v requested for %v: %w", i, req.Purpose, ErrRefused)
+		} else if inHand[card] {
+			return fmt.Errorf("Card %v is in my hand: %w", i, ErrRefused)
+		} else if keys[i] = m.anyCardKey(req.Card); keys[i] == nil || seen[card] {
+			return fmt.Errorf("Card %v: %w", i, ErrUnknownCard)
+		}
+		seen[card] = true
+		reshuffleCards[i] = card
+	}
+	for i, req := range reqs {
+		if err := m.approve(req); err != nil {
+			return fmt.Errorf("Card %v: %w", i, err)
+		}
+	}
+	m.reshuffles++
+	m.reshuffleCards = reshuffleCards
+	defer func() {
+		if err != nil {
+			m.dropShuffle()
+		}
+	}()
+	key, err := m.newKey(ctx, keyLabelReshuffle, 0)
+	if err != nil {
+		return
+	}
+	m.tempReshuffleKey = key
+	// Swap each card key for the reshuffle key
+	if err = sra.RekeyInts(keys, m.tempReshuffleKey, cards, m.Workers); err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// ReshuffleMix impls Player.ReshuffleMix. On failure, the reshuffle is dropped.
+func (m *Me) ReshuffleMix(ctx context.Context, cards []*big.Int) (err error) {
+	if m.tempReshuffleKey == nil || m.tempShuffleStage1Key != nil {
+		return fmt.Errorf("Reshuffle not started: %w", ErrStageOrder)
+	}
+	defer func() {
+		if err != nil {
+			m.dropShuffle()
+		}
+	}()
+	key, err := m.newKey(ctx, keyLabelStage1, 0)
+	if err != nil {
+		return
+	}
+	m.tempShuffleStage1Key = key
+	encs := make([]sra.Cipher, len(cards))
+	for i := range encs {
+		encs[i] = key
+	}
+	if err = sra.ReencryptInts(m.tempReshuffleKey, encs, cards, m.Workers); err != nil {
+		return
+	} else if err = ctx.Err(); err != nil {
+		return
+	}
+	m.tempReshuffleKey.Destroy()
+	m.tempReshuffleKey = nil
+	// Shuffle em
+	newCryptoRand().Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+	return
+}
+
+// DecryptCard impls Player.DecryptCard. Requests for another hand are
+// ErrUnknownCard. Requests refused by Policy, for a retired key, or with an
+// invalid value are ErrRefused. Policy is only asked once the value has been
+// decrypted, so a request with an invalid value never counts against the card.
+func (m *Me) DecryptCard(
+	ctx context.Context,
+	req *DecryptRequest,
+	valToDecrypt *big.Int,
+) (*big.Int, *sra.DecryptProof, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	decrypted, proof, err := m.decrypt(req, valToDecrypt)
+	if err != nil {
+		return nil, nil, err
+	} else if err = m.approve(req); err != nil {
+		return nil, nil, err
+	}
+	m.used(req)
+	return decrypted, proof, nil
+}
+
+// decrypt checks the request and decrypts valToDecrypt for it without
+// approving or recording it.
+func (m *Me) decrypt(
+	req *DecryptRequest,
+	valToDecrypt *big.Int,
+) (decrypted *big.Int, proof *sra.DecryptProof, err error) {
+	if req.HandID != m.handID {
+		return nil, nil, fmt.Errorf("Card is from another hand: %w", ErrUnknownCard)
+	}
+	cardKey := m.cardKeys[req.Card.String()]
+	if cardKey == nil {
+		if m.retiredKeys[req.Card.String()] != nil {
+			return nil, nil, fmt.Errorf("Card key already used: %w", ErrRefused)
+		}
+		return nil, nil, ErrUnknownCard
+	}
+	if prover := m.backend.Prover(); prover != nil {
+		if decrypted, proof, err = prover.ProveDecrypt(rand.Reader, cardKey, valToDecrypt); err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrRefused, err)
+		}
+	} else if decrypted = cardKey.DecryptInt(valToDecrypt); decrypted == nil {
+		return nil, nil, fmt.Errorf("Invalid value to decrypt: %w", ErrRefused)
+	}
+	return decrypted, proof, nil
+}
+
+// approve asks Policy, if any, to approve the request.
+func (m *Me) approve(req *DecryptRequest) error {
+	if m.Policy != nil {
+		if err := m.Policy.Approve(req); err != nil {
+			return fmt.Errorf("%w: %w", ErrRefused, err)
+		}
+	}
+	return nil
+}
+
+// used records that the approved request was decrypted so the key can be
+// retired once the card is revealed.
+func (m *Me) used(req *DecryptRequest) {
+	switch {
+	case req.Purpose == PurposePublicReveal:
+		if m.publicReveals == nil {
+			m.publicReveals = map[string]bool{}
+		}
+		m.publicReveals[req.Card.String()] = true
+	case req.Purpose == PurposeDraw && req.RequesterID != m.id:
+		if m.drawDecrypts == nil {
+			m.drawDecrypts = map[string]bool{}
+		}
+		m.drawDecrypts[req.Card.String()] = true
+	}
+}
+
+// retire retires the key for the fully-encrypted card if RetireUsedKeys is
+// set.
+func (m *Me) retire(origEncryptedCard *big.Int) {
+	key := m.cardKeys[origEncryptedCard.String()]
+	if !m.RetireUsedKeys || key == nil {
+		return
+	}
+	if m.retiredKeys == nil {
+		m.retiredKeys = map[string]sra.Cipher{}
+	}
+	m.retiredKeys[origEncryptedCard.String()] = key
+	delete(m.cardKeys, origEncryptedCard.String())
+}
+
+// PublicCardRevealed impls Player.PublicCardRevealed. The card is only accepted
+// if I decrypted it for a public reveal in this hand.
+func (m *Me) PublicCardRevealed(ctx context.Context, req *DecryptRequest, card *big.Int) error {
+	if req.HandID != m.handID || !m.publicReveals[req.Card.String()] {
+		return fmt.Errorf("Card was not publicly revealed: %w", ErrUnknownCard)
+	}
+	delete(m.publicReveals, req.Card.String())
+	m.retire(req.Card)
+	m.PublicCards = append(m.PublicCards, card)
+	m.PublicEncryptedCards = append(m.PublicEncryptedCards, req.Card)
+	return nil
+}
+
+// CardDrawn impls Player.CardDrawn. The draw is only counted if I decrypted
+// the card for it in this hand.
+func (m *Me) CardDrawn(ctx context.Context, req *DecryptRequest) {
+	if req.HandID == m.handID && m.drawDecrypts[req.Card.String()] {
+		delete(m.drawDecrypts, req.Card.String())
+		m.retire(req.Card)
+	}
+}
+
+// DecryptCards impls Player.DecryptCards. Every request is checked and
+// decrypted, then every request is given to Policy, and only then are the
+// requests recorded, so an invalid request or value leaves every card as it
+// was. A refusal by Policy can still leave the requests before it counted by
+// Policy (e.g. with NeverTwice). A card can only be requested once per call.
+func (m *Me) DecryptCards(
+	ctx context.Context,
+	reqs []*DecryptRequest,
+	valsToDecrypt []*big.Int,
+) ([]*big.Int, []*sra.DecryptProof, error) {
+	if len(reqs) != len(valsToDecrypt) {
+		return nil, nil, fmt.Errorf("Have %v values for %v requests", len(valsToDecrypt), len(reqs))
+	}
+	decrypted := make([]*big.Int, len(reqs))
+	proofs := make([]*sra.DecryptProof, len(reqs))
+	seen := make(map[string]bool, len(reqs))
+	for i, req := range reqs {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		} else if seen[req.Card.String()] {
+			return nil, nil, fmt.Errorf("Card %v requested twice: %w", i, ErrRefused)
+		}
+		seen[req.Card.String()] = true
+		var err error
+		if decrypted[i], proofs[i], err = m.decrypt(req, valsToDecrypt[i]); err != nil {
+			return nil, nil, fmt.Errorf("Card %v: %w", i, err)
+		}
+	}
+	for i, req := range reqs {
+		if err := m.approve(req); err != nil {
+			return nil, nil, fmt.Errorf("Card %v: %w", i, err)
+		}
+	}
+	for _, req := range reqs {
+		m.used(req)
+	}
+	return decrypted, proofs, nil
 }
 
 // DrawCard draws the next card off the deck and puts it in my hand.
-func (m *Me) DrawCard(deck *Deck) error {
+func (m *Me) DrawCard(ctx context.Context, deck *Deck) error {
 	// Grab card decrypted by everyone but me
-	origEncryptedCard, mostlyDecryptedCard, err := deck.DrawCard(m.id)
+	req, mostlyDecryptedCard, err := deck.DrawCard(ctx, m.id)
+	if err != nil {
+		return err
+	}
+	return m.ReceiveCard(ctx, req, mostlyDecryptedCard)
+}
+
+// DrawCards draws the next n cards off the deck with Deck.DrawN and puts them
+// in my hand.
+func (m *Me) DrawCards(ctx context.Context, deck *Deck, n int) error {
+	reqs, mostlyDecryptedCards, err := deck.DrawN(ctx, n, m.id)
 	if err != nil {
 		return err
 	}
+	for i, req := range reqs {
+		if err := m.ReceiveCard(ctx, req, mostlyDecryptedCards[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReceiveCard finishes a draw for me (e.g. from Deck.DrawAt or
+// Deck.DrawBottom) by decrypting the card and putting it in my hand. The card
+// is fully revealed to me then, so its key is retired if RetireUsedKeys is
+// set.
+func (m *Me) ReceiveCard(ctx context.Context, req *DecryptRequest, mostlyDecryptedCard *big.Int) error {
 	// Decrypt it for me which means, as the last one to decrypt, that it is
 	// fully decrypted.
-	decryptedCard := m.DecryptCard(origEncryptedCard, mostlyDecryptedCard)
-	if decryptedCard == nil {
-		return fmt.Errorf("Can't find card decryption key")
+	decryptedCard, _, err := m.DecryptCard(ctx, req, mostlyDecryptedCard)
+	if err != nil {
+		return err
+	}
+	if decryptedCard, err = m.backend.DecodeInt(decryptedCard); err != nil {
+		return err
 	}
+	m.retire(req.Card)
 	m.DecryptedCards = append(m.DecryptedCards, decryptedCard)
-	m.OrigEncryptedCards = append(m.OrigEncryptedCards, origEncryptedCard)
+	m.OrigEncryptedCards = append(m.OrigEncryptedCards, req.Card)
 	return nil
 }
+
+// Discard discards the fully-encrypted card from my hand with Deck.Discard.
+func (m *Me) Discard(deck *Deck, origEncryptedCard *big.Int) error {
+	if err := deck.Discard(m.id, origEncryptedCard); err != nil {
+		return err
+	}
+	m.removeFromHand(origEncryptedCard)
+	return nil
+}
+
+// Play plays the fully-encrypted card from my hand to the table with
+// Deck.Play.
+func (m *Me) Play(deck *Deck, origEncryptedCard *big.Int, faceUp bool) error {
+	if err := deck.Play(m.id, origEncryptedCard, faceUp); err != nil {
+		return err
+	}
+	m.removeFromHand(origEncryptedCard)
+	return nil
+}
+
+func (m *Me) removeFromHand(origEncryptedCard *big.Int) {
+	for i, card := range m.OrigEncryptedCards {
+		if card.Cmp(origEncryptedCard) == 0 {
+			m.OrigEncryptedCards = append(m.OrigEncryptedCards[:i], m.OrigEncryptedCards[i+1:]...)
+			m.DecryptedCards = append(m.DecryptedCards[:i], m.DecryptedCards[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package deck
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Purpose is why a card decryption is requested.
+type Purpose int
+
+const (
+	// PurposeDraw is a card being drawn into the requester's hand. The
+	// requester decrypts it last so only they see it.
+	PurposeDraw Purpose = iota
+	// PurposePublicReveal is a card being shown to every player (e.g. a
+	// community card).
+	PurposePublicReveal
+	// PurposeEndOfGame is a card being shown to every player once the game is
+	// over so the deck can be checked.
+	PurposeEndOfGame
+	// PurposeReshuffle is a card being shuffled back into the deck (e.g. a
+	// discard). It is rekeyed with Player.ReshuffleStart instead of decrypted,
+	// and Me refuses it for cards in its own hand.
+	PurposeReshuffle
+)
+
+func (p Purpose) String() string {
+	switch p {
+	case PurposeDraw:
+		return "draw"
+	case PurposePublicReveal:
+		return "public reveal"
+	case PurposeEndOfGame:
+		return "end of game"
+	case PurposeReshuffle:
+		return "reshuffle"
+	default:
+		return fmt.Sprintf("Purpose(%d)", int(p))
+	}
+}
+
+// DecryptRequest is everything a player is told about a request to decrypt one
+// of its cards, or to rekey it for a reshuffle, so its Policy can decide
+// whether to do it.
+type DecryptRequest struct {
+	// RequesterID is the player asking, or uuid.Nil if the deck is asking on no
+	// player's behalf.
+	RequesterID uuid.UUID
+	// HandID is the hand ID of the shuffle the card is from.
+	HandID uuid.UUID
+	// Card is the fully-encrypted card.
+	Card *big.Int
+	// Position is the index of the card in the deck, counted from the bottom,
+	// at the time of the request or -1 if the card is no longer in the deck.
+	Position int
+	Purpose  Purpose
+	// Phase is the game phase as set with Deck.SetPhase.
+	Phase string
+}
+
+// Policy decides whether a player decrypts a card for a request.
+type Policy interface {
+	// Approve returns nil if the card may be decrypted for req, otherwise an
+	// error saying why not. Me wraps the error with ErrRefused.
+	Approve(req *DecryptRequest) error
+}
+
+// PolicyFunc is a function that impls Policy.
+type PolicyFunc func(req *DecryptRequest) error
+
+// Approve impls Policy.Approve.
+func (p PolicyFunc) Approve(req *DecryptRequest) error { return p(req) }
+
+// AllPolicies returns a policy that approves a request only if every one of
+// the given policies does. The policies are asked in order and the first
+// refusal is returned.
+func AllPolicies(policies ...Policy) Policy {
+	return PolicyFunc(func(req *DecryptRequest) error {
+		for _, policy := range policies {
+			if err := policy.Approve(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OnlyDrawer returns a policy that approves draws only for the player returned
+// by drawer (e.g. whose turn it is). Other purposes are left to other
+// policies.
+func OnlyDrawer(drawer func() uuid.UUID) Policy {
+	return PolicyFunc(func(req *DecryptRequest) error {
+		if req.Purpose == PurposeDraw && req.RequesterID != drawer() {
+			return fmt.Errorf("Player %v is not the current drawer", req.RequesterID)
+		}
+		return nil
+	})
+}
+
+// NeverTwice returns a policy that approves only the first request for each
+// card, whatever its purpose. Reshuffle requests decrypt nothing so they are
+// approved without counting. Only the cards of the latest hand are remembered.
+// Since a request counts once it gets here, this should be last in
+// AllPolicies.
+func NeverTwice() Policy {
+	var mu sync.Mutex
+	var handID uuid.UUID
+	var seen map[string]bool
+	return PolicyFunc(func(req *DecryptRequest) error {
+		if req.Purpose == PurposeReshuffle {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if seen == nil || req.HandID != handID {
+			handID, seen = req.HandID, map[string]bool{}
+		}
+		if seen[req.Card.String()] {
+			return fmt.Errorf("Card already decrypted")
+		}
+		seen[req.Card.String()] = true
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package deck
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+// Zone is where a card of the current hand is.
+type Zone int
+
+const (
+	// ZoneDeck is a card still in the deck.
+	ZoneDeck Zone = iota
+	// ZoneHand is a card drawn into its owner's hand.
+	ZoneHand
+	// ZoneTableFaceUp is a card on the table that every player has seen, either
+	// from Deck.RevealPublic (with no owner) or played face up by its owner.
+	ZoneTableFaceUp
+	// ZoneTableFaceDown is a card played face down on the table by its owner.
+	ZoneTableFaceDown
+	// ZoneDiscard is a card discarded by its owner.
+	ZoneDiscard
+	// ZoneBurn is a card taken off the deck without being decrypted by anyone.
+	ZoneBurn
+)
+
+func (z Zone) String() string {
+	switch z {
+	case ZoneDeck:
+		return "deck"
+	case ZoneHand:
+		return "hand"
+	case ZoneTableFaceUp:
+		return "table face up"
+	case ZoneTableFaceDown:
+		return "table face down"
+	case ZoneDiscard:
+		return "discard"
+	case ZoneBurn:
+		return "burn"
+	default:
+		return fmt.Sprintf("Zone(%d)", int(z))
+	}
+}
+
+// CardLocation is the zone of a card and the player it belongs to, if any.
+type CardLocation struct {
+	Zone Zone
+	// Owner is the player whose card it is, or uuid.Nil for cards in the deck,
+	// burned, or revealed publicly. Drawn cards are owned by the player they
+	// were left encrypted for.
+	Owner uuid.UUID
+}
+
+// Location returns where the fully-encrypted card is and false if it is not
+// from the current shuffle. Every card move done through the deck is tracked,
+// so at the end of a game the locations can be checked against what every
+// player disclosed and claims to hold.
+func (d *Deck) Location(origEncryptedCard *big.Int) (CardLocation, bool) {
+	loc, ok := d.locations[origEncryptedCard.String()]
+	return loc, ok
+}
+
+// CardsIn returns the fully-encrypted cards of the current shuffle in the zone
+// and owned by owner, in the order the shuffle completed them.
+func (d *Deck) CardsIn(zone Zone, owner uuid.UUID) []*big.Int {
+	var cards []*big.Int
+	for _, card := range d.shuffled {
+		if d.locations[card.String()] == (CardLocation{Zone: zone, Owner: owner}) {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// Burn takes a card off the end of the deck without any player decrypting it
+// and returns it.
+func (d *Deck) Burn() (*big.Int, error) {
+	if len(d.cards) == 0 {
+		return nil, ErrDeckEmpty
+	}
+	card := d.cards[len(d.cards)-1]
+	d.cards = d.cards[:len(d.cards)-1]
+	d.locations[card.String()] = CardLocation{Zone: ZoneBurn}
+	return card, nil
+}
+
+// Discard moves the fully-encrypted card from the player's hand to the discard
+// zone.
+func (d *Deck) Discard(playerID uuid.UUID, origEncryptedCard *big.Int) error {
+	return d.moveFromHand(playerID, origEncryptedCard, ZoneDiscard)
+}
+
+// Play moves the fully-encrypted card from the player's hand to the table,
+// face up or down. The deck only records the move. Showing a face-up card's
+// value is up to the player. Its key from Me.CardKey alone isn't enough since
+// it only removes the last layer, so the player also has to publish the
+// mostly-decrypted card it got when drawing, which the others can check
+// against the decryptions they gave.
+func (d *Deck) Play(playerID uuid.UUID, origEncryptedCard *big.Int, faceUp bool) error {
+	zone := ZoneTableFaceDown
+	if faceUp {
+		zone = ZoneTableFaceUp
+	}
+	return d.moveFromHand(playerID, origEncryptedCard, zone)
+}
+
+func (d *Deck) isPlayer(playerID uuid.UUID) bool {
+	for _, player := range d.players {
+		if player.ID() == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Deck) moveFromHand(playerID uuid.UUID, origEncryptedCard *big.Int, zone Zone) error {
+	loc, ok := d.locations[origEncryptedCard.String()]
+	if !ok {
+		return ErrUnknownCard
+	} else if loc != (CardLocation{Zone: ZoneHand, Owner: playerID}) {
+		return fmt.Errorf("Card is not in the hand of %v, it is in %v", playerID, loc.Zone)
+	}
+	d.locations[origEncryptedCard.String()] = CardLocation{Zone: zone, Owner: playerID}
+	return nil
+}
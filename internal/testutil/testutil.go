@@ -0,0 +1,24 @@
+// Package testutil contains helpers shared by the tests of this module.
+package testutil
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+// NewUnsafePrime returns a random prime of the given bits that is not a safe
+// prime, which a plain random prime is with a small but real chance.
+func NewUnsafePrime(t testing.TB, bits int) *big.Int {
+	t.Helper()
+	for {
+		prime, err := rand.Prime(rand.Reader, bits)
+		require.NoError(t, err)
+		if !sra.IsSafePrime(prime) {
+			return prime
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Package attack is a cryptanalysis harness showing what an adversarial player
+// learns from an honest deck.Me under a given backend. Each attack plays a
+// shuffle as one of the players and reports a Finding when it succeeds, so
+// config changes can be gated on Run coming back empty.
+package attack
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+
+	"github.com/cretz/go-mental-poker/deck"
+	"github.com/cretz/go-mental-poker/sra"
+)
+
+// Finding is a successful attack.
+type Finding struct {
+	// Attack is the name of the attack.
+	Attack string
+	// Detail describes what was learned.
+	Detail string
+}
+
+func (f *Finding) String() string { return f.Attack + ": " + f.Detail }
+
+// Attack is a single named attack against a played game.
+type Attack struct {
+	Name string
+	// Run returns nil if the attack did not succeed, including when it doesn't
+	// apply to the backend. Findings don't need Attack set.
+	Run func(g *Game) (*Finding, error)
+}
+
+// Attacks are every attack run by Run.
+var Attacks = []Attack{
+	{Name: "legendre", Run: LegendreLeak},
+	{Name: "bsgs", Run: SmallExponent},
+	{Name: "small-subgroup", Run: SmallSubgroup},
+	{Name: "negated-card", Run: NegatedCard},
+}
+
+// CardCount is the number of cards in the attacked deck, valued 2 to 53.
+const CardCount = 52
+
+// Run plays a game with the backend and runs every attack against it,
+// returning the findings of the successful ones.
+func Run(backend sra.Backend) ([]*Finding, error) {
+	g, err := NewGame(backend)
+	if err != nil {
+		return nil, err
+	}
+	var findings []*Finding
+	for _, attack := range Attacks {
+		finding, err := attack.Run(g)
+		if err != nil {
+			return nil, fmt.Errorf("Attack %v failed: %w", attack.Name, err)
+		} else if finding != nil {
+			finding.Attack = attack.Name
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// Game is a shuffled deck between an honest Victim and an attacker that sat
+// right after the victim and recorded everything it was sent.
+type Game struct {
+	Backend sra.Backend
+	Deck    *deck.Deck
+	Victim  *deck.Me
+	// Attacker's own player
+	Attacker *deck.Me
+	// Plain are the encoded plain cards, which every player knows.
+	Plain []*big.Int
+	// VictimStage1 are the cards after only the victim's stage-1 encryption and
+	// shuffle, as sent to the attacker.
+	VictimStage1 []*big.Int
+	// Final are the fully-encrypted cards after the shuffle.
+	Final []*big.Int
+}
+
+// NewGame shuffles a deck between a new deck.Me victim and an attacker.
+func NewGame(backend sra.Backend) (*Game, error) {
+	g := &Game{Backend: backend, Victim: deck.NewMe(backend), Attacker: deck.NewMe(backend)}
+	g.Plain = make([]*big.Int, CardCount)
+	for i := range g.Plain {
+		var err error
+		if g.Plain[i], err = backend.EncodeInt(big.NewInt(int64(i + 2))); err != nil {
+			return nil, err
+		}
+	}
+	g.Deck = deck.New(backend, []deck.Player{g.Victim, &spy{Me: g.Attacker, game: g}}, CardCount)
+	if err := g.Deck.ResetAndShuffle(context.Background()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// plainOf is the ground truth for a final card using both players' keys. This
+// is only used to score attacks, never by the attacks themselves.
+func (g *Game) plainOf(final *big.Int) *big.Int {
+	v := g.Attacker.CardKey(final).DecryptInt(final)
+	return g.Victim.CardKey(final).DecryptInt(v)
+}
+
+// spy is the attacker's player which records what it is sent.
+type spy struct {
+	*deck.Me
+	game *Game
+}
+
+func (s *spy) ShuffleStage1(ctx context.Context, handID uuid.UUID, cards []*big.Int) error {
+	s.game.VictimStage1 = append([]*big.Int(nil), cards...)
+	return s.Me.ShuffleStage1(ctx, handID, cards)
+}
+
+func (s *spy) ShuffleComplete(ctx context.Context, cards []*big.Int) ([]*big.Int, error) {
+	s.game.Final = append([]*big.Int(nil), cards...)
+	return s.Me.ShuffleComplete(ctx, cards)
+}
+
+// LegendreLeak checks whether the Legendre symbol of each fully-encrypted card
+// is the same as its plain card. If so, and the plain cards don't all have the
+// same symbol, the attacker can split the deck into two known groups before
+// anything is drawn. This applies to SRA in the full group of a prime.
+func LegendreLeak(g *Game) (*Finding, error) {
+	backend, ok := g.Backend.(*sra.SRABackend)
+	if !ok {
+		return nil, nil
+	}
+	p := backend.Params.P
+	residues := 0
+	for _, card := range g.Plain {
+		if big.Jacobi(card, p) == 1 {
+			residues++
+		}
+	}
+	if residues == 0 || residues == len(g.Plain) {
+		return nil, nil
+	}
+	for _, card := range g.Final {
+		if big.Jacobi(card, p) != big.Jacobi(g.plainOf(card), p) {
+			return nil, nil
+		}
+	}
+	return &Finding{
+		Detail: fmt.Sprintf("encrypted cards split into %v residues and %v non-residues by Legendre symbol",
+			residues, len(g.Plain)-residues),
+	}, nil
+}
+
+// SmallExponent uses baby-step giant-step to look for an Enc under 2^32 that
+// the victim used for stage 1, given that the victim's stage-1 output is every
+// known plain card encrypted with it. Recovering it reveals the victim's
+// stage-1 shuffle. This applies to SRA.
+func SmallExponent(g *Game) (*Finding, error) {
+	backend, ok := g.Backend.(*sra.SRABackend)
+	if !ok {
+		return nil, nil
+	}
+	p := backend.Params.P
+	base := g.Plain[0]
+	baseInv := new(big.Int).ModInverse(base, p)
+	if baseInv == nil {
+		return nil, nil
+	}
+	// Baby steps: every output card times base^-j, so a match on giant step i
+	// means the card is base^(i * m + j)
+	const m = 1 << 13
+	baby := make(map[uint64]uint32, m*len(g.VictimStage1))
+	for _, card := range g.VictimStage1 {
+		v := new(big.Int).Set(card)
+		for j := uint32(0); j < m; j++ {
+			baby[lowWord(v)] = j
+			v.Mul(v, baseInv).Mod(v, p)
+		}
+	}
+	giantStep := new(big.Int).Exp(base, big.NewInt(m), p)
+	giant := big.NewInt(1)
+	for i := uint64(0); i < (1<<32)/m; i++ {
+		// Low word matches are confirmed against every card
+		if j, ok := baby[lowWord(giant)]; ok {
+			enc := new(big.Int).SetUint64(i*m + uint64(j))
+			if encryptsAll(g.Plain, g.VictimStage1, enc, p) {
+				return &Finding{Detail: fmt.Sprintf("recovered victim's stage-1 exponent %v", enc)}, nil
+			}
+		}
+		giant.Mul(giant, giantStep).Mod(giant, p)
+	}
+	return nil, nil
+}
+
+func lowWord(v *big.Int) uint64 {
+	if words := v.Bits(); len(words) > 0 {
+		return uint64(words[0])
+	}
+	return 0
+}
+
+// encryptsAll reports whether plain encrypted with enc is the same set as
+// encrypted.
+func encryptsAll(plain []*big.Int, encrypted []*big.Int, enc *big.Int, p *big.Int) bool {
+	want := make(map[string]bool, len(encrypted))
+	for _, v := range encrypted {
+		want[v.String()] = true
+	}
+	for _, v := range plain {
+		if !want[new(big.Int).Exp(v, enc, p).String()] {
+			return false
+		}
+	}
+	return true
+}
+
+// SmallSubgroup confines values to small subgroups of Z*_p by sending the
+// victim elements of small prime order r as the value to decrypt for one of
+// its cards, then brute-forces the victim's card key mod r from the result.
+// With a non-safe prime, p-1 usually has many small factors and this leaks the
+// key bit by bit. A safe prime's only small subgroup is {1, p-1} of order 2,
+// which would show whether the key is odd. Keys in the residue subgroup can be
+// even or odd, so that is a real leak, but it isn't tried here since p-1 is not
+// a residue and key pairs refuse it like any other value outside the subgroup
+// (see NegatedCard). This applies to SRA.
+func SmallSubgroup(g *Game) (*Finding, error) {
+	backend, ok := g.Backend.(*sra.SRABackend)
+	if !ok {
+		return nil, nil
+	}
+	p := backend.Params.P
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+	// Ask as a draw of the top card by the attacker
+	req := &deck.DecryptRequest{
+		RequesterID: g.Attacker.ID(),
+		HandID:      g.Deck.HandID(),
+		Card:        g.Final[len(g.Final)-1],
+		Position:    len(g.Final) - 1,
+		Purpose:     deck.PurposeDraw,
+	}
+	modulus := big.NewInt(1)
+	for r := int64(3); r < 1<<12; r += 2 {
+		order := big.NewInt(r)
+		if !order.ProbablyPrime(0) || new(big.Int).Mod(pMinus1, order).Sign() != 0 {
+			continue
+		}
+		// Element of order r
+		h, err := elementOfOrder(p, pMinus1, order)
+		if err != nil {
+			return nil, err
+		}
+		// Stop once the victim refuses (e.g. by its policy)
+		confined, _, err := g.Victim.DecryptCard(context.Background(), req, h)
+		if err != nil {
+			break
+		}
+		// Brute force the key mod r
+		v := big.NewInt(1)
+		for t := int64(0); t < r; t++ {
+			if v.Cmp(confined) == 0 {
+				modulus.Mul(modulus, order)
+				break
+			}
+			v.Mul(v, h).Mod(v, p)
+		}
+	}
+	if modulus.Cmp(big.NewInt(1)) == 0 {
+		return nil, nil
+	}
+	return &Finding{Detail: fmt.Sprintf("learned victim's card key mod %v (%v bits)", modulus, modulus.BitLen()-1)}, nil
+}
+
+// negatedCardHands is the number of hands NegatedCard tries. Each works with
+// about a 1/2 chance.
+const negatedCardHands = 20
+
+// NegatedCard plays the player right before a victim in stage 1 and marks one
+// card by negating it (i.e. sending p - x). With a safe prime, every other
+// card is in the residue subgroup and the marked one is not. Whenever the
+// victim's exponent is odd, it is still the only non-residue after the
+// victim's encryption, so it can be followed through the victim's shuffle. A
+// colluding later player can negate it back so nobody notices. Each hand is
+// played against a new victim like Game.Victim so the game's hand is left
+// alone, and the attack fails once a victim refuses the marked card. This
+// applies to SRA with a safe prime, since otherwise LegendreLeak already splits
+// the deck.
+func NegatedCard(g *Game) (*Finding, error) {
+	backend, ok := g.Backend.(*sra.SRABackend)
+	if !ok || backend.Params.Q == nil {
+		return nil, nil
+	}
+	p := backend.Params.P
+	for hand := 0; hand < negatedCardHands; hand++ {
+		cards := make([]*big.Int, len(g.Plain))
+		for i, card := range g.Plain {
+			cards[i] = new(big.Int).Set(card)
+		}
+		cards[0].Sub(p, cards[0])
+		handID, err := uuid.NewRandom()
+		if err != nil {
+			return nil, err
+		}
+		if err := deck.NewMe(g.Backend).ShuffleStage1(context.Background(), handID, cards); err != nil {
+			return nil, nil
+		}
+		// Since residues stay residues, a single non-residue is the marked card
+		marked := -1
+		for i, card := range cards {
+			if big.Jacobi(card, p) != 1 {
+				if marked >= 0 {
+					marked = -1
+					break
+				}
+				marked = i
+			}
+		}
+		if marked >= 0 {
+			return &Finding{
+				Detail: fmt.Sprintf("followed negated card through victim's stage-1 shuffle to position %v on hand %v",
+					marked, hand+1),
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// elementOfOrder returns a random element of Z*_p of the given prime order
+// which must divide p-1.
+func elementOfOrder(p *big.Int, pMinus1 *big.Int, order *big.Int) (*big.Int, error) {
+	cofactor := new(big.Int).Div(pMinus1, order)
+	for {
+		x, err := rand.Int(rand.Reader, pMinus1)
+		if err != nil {
+			return nil, err
+		}
+		x.Add(x, big.NewInt(1))
+		if h := x.Exp(x, cofactor, p); h.Cmp(big.NewInt(1)) != 0 {
+			return h, nil
+		}
+	}
+}
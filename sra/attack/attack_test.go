@@ -0,0 +1,50 @@
+package attack_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/cretz/go-mental-poker/sra/attack"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDefaultsHaveNoFindings gates the default configs on the harness
+func TestDefaultsHaveNoFindings(t *testing.T) {
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.MODP2048}, sra.P256} {
+		findings, err := attack.Run(backend)
+		require.NoError(t, err)
+		require.Empty(t, findings)
+	}
+}
+
+// TestWeakConfigFindings confirms every attack works against the original
+// configuration of a non-safe prime and 32-bit prime exponents
+func TestWeakConfigFindings(t *testing.T) {
+	backend := &sra.SRABackend{
+		Params:  sra.NewUncheckedParams(smoothPrime(t)),
+		Options: sra.GenerateOptions{PrimeExponentBits: 32, NoSwap: true},
+	}
+	findings, err := attack.Run(backend)
+	require.NoError(t, err)
+	var names []string
+	for _, finding := range findings {
+		t.Log(finding)
+		names = append(names, finding.Attack)
+	}
+	require.Equal(t, []string{"legendre", "bsgs", "small-subgroup"}, names)
+}
+
+// smoothPrime returns a 256-bit prime p where p-1 has several small factors.
+func smoothPrime(t *testing.T) *big.Int {
+	smooth := big.NewInt(2 * 3 * 5 * 7 * 11 * 13)
+	for {
+		k, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 240))
+		require.NoError(t, err)
+		p := k.Mul(k.SetBit(k, 239, 1), smooth).Add(k, big.NewInt(1))
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
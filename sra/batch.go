@@ -0,0 +1,138 @@
+package sra
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// The batch helpers below do one exponentiation (or scalar multiplication) per
+// value. Constant-time key pairs use ctExp, which reuses the Montgomery setup
+// cached per prime in montgomeryCache. Other key pairs keep big.Int.Exp: a
+// variable-time exponentiation on the same cached setup was measured at about
+// 18ms per value for MODP2048 against 6.5ms for big.Int.Exp, whose inner loop
+// is assembly. The speedup comes from the workers and from ReencryptInts and
+// RekeyInts needing one exponentiation per value instead of two.
+
+// EncryptInts encrypts every value in vs in place with c, spreading the work
+// across workers goroutines. If workers is less than 1, GOMAXPROCS is used. An
+// error is returned if any value is not valid for the cipher, in which case
+// some values may have already been replaced.
+func EncryptInts(c Cipher, vs []*big.Int, workers int) error {
+	return ParallelFor(len(vs), workers, func(i int) error {
+		if vs[i] = c.EncryptInt(vs[i]); vs[i] == nil {
+			return fmt.Errorf("Invalid value at %v", i)
+		}
+		return nil
+	})
+}
+
+// DecryptInts decrypts every value in vs in place with c. See EncryptInts for
+// how workers and errors are handled.
+func DecryptInts(c Cipher, vs []*big.Int, workers int) error {
+	return ParallelFor(len(vs), workers, func(i int) error {
+		if vs[i] = c.DecryptInt(vs[i]); vs[i] == nil {
+			return fmt.Errorf("Invalid value at %v", i)
+		}
+		return nil
+	})
+}
+
+// ReencryptInts replaces every value in vs with it decrypted by dec and then
+// encrypted by the cipher at the same index in encs. When dec and the encs are
+// both SRA key pairs for the same prime or both EC key pairs for the same
+// curve, the exponents (or scalars) are multiplied first so each value only
+// needs one exponentiation instead of two. That multiplication isn't constant
+// time, so it is skipped for constant-time key pairs. See EncryptInts for how
+// workers and errors are handled.
+func ReencryptInts(dec Cipher, encs []Cipher, vs []*big.Int, workers int) error {
+	if len(encs) != len(vs) {
+		return fmt.Errorf("Have %v ciphers for %v values", len(encs), len(vs))
+	}
+	return ParallelFor(len(vs), workers, func(i int) error {
+		return reencryptAt(dec, encs[i], vs, i)
+	})
+}
+
+// RekeyInts is ReencryptInts the other way around. Every value in vs is
+// decrypted by the cipher at the same index in decs and then encrypted by enc.
+func RekeyInts(decs []Cipher, enc Cipher, vs []*big.Int, workers int) error {
+	if len(decs) != len(vs) {
+		return fmt.Errorf("Have %v ciphers for %v values", len(decs), len(vs))
+	}
+	return ParallelFor(len(vs), workers, func(i int) error {
+		return reencryptAt(decs[i], enc, vs, i)
+	})
+}
+
+func reencryptAt(dec Cipher, enc Cipher, vs []*big.Int, i int) error {
+	if combined := combineDecryptEncrypt(dec, enc); combined != nil {
+		vs[i] = combined.EncryptInt(vs[i])
+		combined.Destroy()
+	} else if vs[i] = dec.DecryptInt(vs[i]); vs[i] != nil {
+		vs[i] = enc.EncryptInt(vs[i])
+	}
+	if vs[i] == nil {
+		return fmt.Errorf("Invalid value at %v", i)
+	}
+	return nil
+}
+
+// combineDecryptEncrypt returns a cipher whose EncryptInt is the same as
+// decrypting with dec and encrypting with enc, or nil if they can't be
+// combined.
+func combineDecryptEncrypt(dec Cipher, enc Cipher) Cipher {
+	switch dec := dec.(type) {
+	case *KeyPair:
+		if enc, ok := enc.(*KeyPair); ok && dec.Prime.Cmp(enc.Prime) == 0 && !dec.ConstantTime && !enc.ConstantTime {
+			// Exponents can always be reduced mod p-1, even when they are only
+			// inverses mod the smaller subgroup order
+			exp := new(big.Int).Mul(dec.Dec, enc.Enc)
+			return &KeyPair{Prime: dec.Prime, Enc: exp.Mod(exp, new(big.Int).Sub(dec.Prime, bigOne))}
+		}
+	case *ECKeyPair:
+		if enc, ok := enc.(*ECKeyPair); ok && dec.Curve == enc.Curve {
+			scalar := new(big.Int).Mul(dec.Dec, enc.Enc)
+			return &ECKeyPair{Curve: dec.Curve, Enc: scalar.Mod(scalar, dec.Curve.Params().N)}
+		}
+	}
+	return nil
+}
+
+// ParallelFor runs fn for every index under n across workers goroutines. If
+// workers is less than 1, GOMAXPROCS is used. Once fn fails, no new indexes
+// are started, and every error that occurred is returned joined in index
+// order. This is what the batch helpers use and is exported for other
+// per-value work like generating keys.
+func ParallelFor(n int, workers int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	errs := make([]error, n)
+	var next int64 = -1
+	var failed int32
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&failed) == 0 {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				if errs[i] = fn(i); errs[i] != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
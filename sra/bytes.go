@@ -0,0 +1,68 @@
+package sra
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// bytesPrefix is put before byte strings when they are made into ints so that
+// leading zero bytes are kept and the int is never 0 or 1.
+const bytesPrefix = 0x01
+
+// BytesToInt reversibly converts a byte string to a positive int by prefixing
+// it with a 0x01 byte. Non-empty byte strings always give an int over 255.
+func BytesToInt(b []byte) *big.Int {
+	return new(big.Int).SetBytes(append([]byte{bytesPrefix}, b...))
+}
+
+// IntToBytes is the reverse of BytesToInt. It fails if v was not made by
+// BytesToInt.
+func IntToBytes(v *big.Int) ([]byte, error) {
+	b := v.Bytes()
+	if v.Sign() <= 0 || b[0] != bytesPrefix {
+		return nil, fmt.Errorf("Value is not a byte string")
+	}
+	return b[1:], nil
+}
+
+// EncodeBytes maps a non-empty byte string into the backend's group with
+// BytesToInt and Backend.EncodeInt. It fails if the byte string is too long for
+// the group (e.g. over 30 bytes for P256).
+func EncodeBytes(backend Backend, b []byte) (*big.Int, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("Byte string is empty")
+	}
+	v, err := backend.EncodeInt(BytesToInt(b))
+	if err != nil {
+		return nil, fmt.Errorf("Byte string too long: %w", err)
+	}
+	return v, nil
+}
+
+// DecodeBytes is the reverse of EncodeBytes.
+func DecodeBytes(backend Backend, v *big.Int) ([]byte, error) {
+	decoded, err := backend.DecodeInt(v)
+	if err != nil {
+		return nil, err
+	}
+	return IntToBytes(decoded)
+}
+
+// EncryptBytes encodes b with EncodeBytes and encrypts it with c.
+func EncryptBytes(backend Backend, c Cipher, b []byte) (*big.Int, error) {
+	v, err := EncodeBytes(backend, b)
+	if err != nil {
+		return nil, err
+	} else if v = c.EncryptInt(v); v == nil {
+		return nil, fmt.Errorf("Invalid value for cipher")
+	}
+	return v, nil
+}
+
+// DecryptBytes decrypts v with c and decodes it with DecodeBytes.
+func DecryptBytes(backend Backend, c Cipher, v *big.Int) ([]byte, error) {
+	if v = c.DecryptInt(v); v == nil {
+		return nil, fmt.Errorf("Invalid value for cipher")
+	}
+	return DecodeBytes(backend, v)
+}
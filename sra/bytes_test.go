@@ -0,0 +1,46 @@
+package sra_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytes(t *testing.T) {
+	for _, b := range [][]byte{{0}, {0, 0, 1}, []byte("Ace of spades"), bytes.Repeat([]byte{0xff}, 100)} {
+		v, err := sra.IntToBytes(sra.BytesToInt(b))
+		require.NoError(t, err)
+		require.Equal(t, b, v)
+	}
+	_, err := sra.IntToBytes(big.NewInt(0x0201))
+	require.Error(t, err)
+	_, err = sra.IntToBytes(big.NewInt(0))
+	require.Error(t, err)
+
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.MODP2048}, sra.P256} {
+		alice, err := backend.GenerateCipher(context.Background(), rand.Reader)
+		require.NoError(t, err)
+		bob, err := backend.GenerateCipher(context.Background(), rand.Reader)
+		require.NoError(t, err)
+		for _, b := range [][]byte{{0}, []byte("Ace of spades"), bytes.Repeat([]byte{0xff}, 30)} {
+			encrypted, err := sra.EncryptBytes(backend, alice, b)
+			require.NoError(t, err)
+			encrypted = bob.EncryptInt(encrypted)
+			decrypted, err := sra.DecryptBytes(backend, alice, bob.DecryptInt(encrypted))
+			require.NoError(t, err)
+			require.Equal(t, b, decrypted)
+		}
+		_, err = sra.EncodeBytes(backend, nil)
+		require.Error(t, err)
+		_, err = sra.EncodeBytes(backend, bytes.Repeat([]byte{0xff}, 300))
+		require.Error(t, err)
+	}
+	// Just past the limit for P256
+	_, err = sra.EncodeBytes(sra.P256, bytes.Repeat([]byte{0xff}, 31))
+	require.Error(t, err)
+}
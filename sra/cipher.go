@@ -0,0 +1,65 @@
+package sra
+
+import (
+	"context"
+	"io"
+	"math/big"
+)
+
+// Cipher is a commutative cipher key. A value encrypted by several ciphers, in
+// any order, can be decrypted by those same ciphers in any order. Values are
+// group elements represented as big ints and must come from Backend.EncodeInt
+// or a previous encryption. Implementations must check that every value is an
+// element of their group since values come from other players. KeyPair does
+// this with KeyPair.Valid and ECKeyPair by checking the point is on the curve.
+type Cipher interface {
+	// EncryptInt returns v encrypted. The result is nil if v is not a valid
+	// element for this cipher.
+	EncryptInt(v *big.Int) *big.Int
+
+	// DecryptInt returns v decrypted. The result is nil if v is not a valid
+	// element for this cipher.
+	DecryptInt(v *big.Int) *big.Int
+
+	// Destroy wipes the key material from memory. The cipher must not be used
+	// afterwards.
+	Destroy()
+}
+
+// Backend generates commutative ciphers and maps plain values into the group
+// the ciphers work in. Every player must use the same backend.
+type Backend interface {
+	// GenerateCipher generates a new random cipher using rnd. It stops with
+	// ctx's error when ctx is done.
+	GenerateCipher(ctx context.Context, rnd io.Reader) (Cipher, error)
+
+	// EncodeInt maps a small positive value (e.g. a card) into the group.
+	EncodeInt(v *big.Int) (*big.Int, error)
+
+	// DecodeInt is the reverse of EncodeInt.
+	DecodeInt(v *big.Int) (*big.Int, error)
+
+	// Prover returns the prover for commitments and decryption proofs of this
+	// backend's ciphers, or nil if the backend doesn't support proofs.
+	Prover() *Prover
+}
+
+// SRABackend is a Backend for SRA key pairs over Params.
+type SRABackend struct {
+	// Params is the shared group.
+	Params *Params
+	// Options are the key generation options. The zero value is the hardened
+	// default.
+	Options GenerateOptions
+}
+
+// GenerateCipher impls Backend.GenerateCipher with GenerateKeyPairContext.
+func (s *SRABackend) GenerateCipher(ctx context.Context, rnd io.Reader) (Cipher, error) {
+	return GenerateKeyPairContext(ctx, rnd, s.Params, s.Options)
+}
+
+// EncodeInt impls Backend.EncodeInt with Params.Encode.
+func (s *SRABackend) EncodeInt(v *big.Int) (*big.Int, error) { return s.Params.Encode(v) }
+
+// DecodeInt impls Backend.DecodeInt with Params.Decode.
+func (s *SRABackend) DecodeInt(v *big.Int) (*big.Int, error) { return s.Params.Decode(v) }
@@ -0,0 +1,85 @@
+package sra
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"math/big"
+)
+
+// Commit returns a public commitment to the decryption key of c that binds the
+// owner to the key before it is used. If the backend has a prover, this is
+// Prover.Commit so decryptions can be proven against it. Otherwise, it is a
+// SHA-256 hash of the key which can only be checked once the key is disclosed.
+// Note, the hash has no random blinding so it only hides keys that can't be
+// guessed (i.e. not the small exponents from GenerateOptions.PrimeExponentBits).
+func Commit(backend Backend, c Cipher) (*big.Int, error) {
+	if prover := backend.Prover(); prover != nil {
+		return prover.Commit(c)
+	}
+	h := sha256.New()
+	h.Write([]byte("sra key commitment v1"))
+	switch c := c.(type) {
+	case *KeyPair:
+		writeHashInt(h, c.Prime)
+		writeHashInt(h, c.Dec)
+	case *ECKeyPair:
+		h.Write([]byte(c.Curve.Params().Name))
+		writeHashInt(h, c.Dec)
+	default:
+		return nil, fmt.Errorf("Unknown cipher type")
+	}
+	return new(big.Int).SetBytes(h.Sum(nil)), nil
+}
+
+// CommitAll returns Commit for every cipher, spreading the work across workers
+// goroutines. If workers is less than 1, GOMAXPROCS is used.
+func CommitAll(backend Backend, cs []Cipher, workers int) ([]*big.Int, error) {
+	ret := make([]*big.Int, len(cs))
+	err := ParallelFor(len(cs), workers, func(i int) (err error) {
+		ret[i], err = Commit(backend, cs[i])
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// VerifyCommitment checks that the disclosed c is the key behind commitment and
+// that its encryption and decryption keys are inverses.
+func VerifyCommitment(backend Backend, commitment *big.Int, c Cipher) error {
+	if commitment == nil || c == nil {
+		return fmt.Errorf("Missing commitment or key")
+	}
+	switch c := c.(type) {
+	case *KeyPair:
+		if err := c.checkConsistent(); err != nil {
+			return err
+		}
+	case *ECKeyPair:
+		if c.Enc == nil || c.Dec == nil {
+			return fmt.Errorf("Key pair missing values")
+		}
+		product := new(big.Int).Mul(c.Enc, c.Dec)
+		if product.Mod(product, c.Curve.Params().N).Cmp(bigOne) != 0 {
+			return fmt.Errorf("Key pair scalars are not inverses")
+		}
+	}
+	expected, err := Commit(backend, c)
+	if err != nil {
+		return err
+	} else if expected.Cmp(commitment) != 0 {
+		return fmt.Errorf("Key does not match commitment")
+	}
+	return nil
+}
+
+// writeHashInt writes the length-prefixed bytes of v so consecutive values
+// can't be shifted into each other.
+func writeHashInt(h hash.Hash, v *big.Int) {
+	b := v.Bytes()
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(b))))
+	h.Write(b)
+}
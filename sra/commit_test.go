@@ -0,0 +1,35 @@
+package sra_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/internal/testutil"
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommit(t *testing.T) {
+	prime := testutil.NewUnsafePrime(t, 256)
+	// Hash commitments without a prover and group commitments with one
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.NewUncheckedParams(prime)}, sra.P256} {
+		keys := make([]sra.Cipher, 3)
+		for i := range keys {
+			var err error
+			keys[i], err = backend.GenerateCipher(context.Background(), rand.Reader)
+			require.NoError(t, err)
+		}
+		commitments, err := sra.CommitAll(backend, keys, 0)
+		require.NoError(t, err)
+		require.Len(t, commitments, len(keys))
+		for i, key := range keys {
+			commitment, err := sra.Commit(backend, key)
+			require.NoError(t, err)
+			require.Equal(t, commitments[i], commitment)
+			require.NoError(t, sra.VerifyCommitment(backend, commitment, key))
+			require.Error(t, sra.VerifyCommitment(backend, commitment, keys[(i+1)%len(keys)]))
+		}
+		require.Error(t, sra.VerifyCommitment(backend, nil, keys[0]))
+	}
+}
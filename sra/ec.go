@@ -0,0 +1,137 @@
+package sra
+
+import (
+	"context"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ECBackend is a Backend for Pohlig-Hellman (a.k.a. Massey-Omura) style
+// ciphers over a prime-order elliptic curve. Encryption is scalar
+// multiplication and decryption is multiplication by the inverse scalar.
+// Since the curve order is prime, every value is in the same group and there is
+// nothing like a Legendre symbol to leak.
+//
+// Group elements are represented as the big int of the compressed point
+// encoding (i.e. 0x02 or 0x03 followed by x). This makes them much smaller than
+// SRA values at the same security level and keeps the Cipher interface the
+// same.
+type ECBackend struct {
+	// Curve is the curve to use. It must have a prime order (i.e. a cofactor
+	// of 1) which is true for all of the NIST curves in crypto/elliptic.
+	Curve elliptic.Curve
+}
+
+// P256 is an ECBackend for the NIST P-256 curve.
+var P256 = &ECBackend{Curve: elliptic.P256()}
+
+// ECKeyPair is a commutative key pair of scalars over an elliptic curve.
+type ECKeyPair struct {
+	// Curve is the curve the scalars are for.
+	Curve elliptic.Curve
+	// Enc is the scalar used to encrypt.
+	Enc *big.Int
+	// Dec is the inverse of Enc mod the curve order and is used to decrypt.
+	Dec *big.Int
+}
+
+// ecEncodeTries is the number of x values to try for each encoded value. Each
+// try has about a 1/2 chance of landing on the curve.
+const ecEncodeTries = 256
+
+// GenerateCipher impls Backend.GenerateCipher with GenerateECKeyPair.
+func (e *ECBackend) GenerateCipher(ctx context.Context, rnd io.Reader) (Cipher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return GenerateECKeyPair(rnd, e.Curve)
+}
+
+// EncodeInt impls Backend.EncodeInt. It maps v to the first point whose x is
+// v * 256 + i for some i under 256. Since each try fails with about 1/2
+// probability, the chance of not finding one is negligible.
+func (e *ECBackend) EncodeInt(v *big.Int) (*big.Int, error) {
+	params := e.Curve.Params()
+	if v.Sign() <= 0 {
+		return nil, fmt.Errorf("Value out of range")
+	}
+	x := new(big.Int).Lsh(v, 8)
+	if new(big.Int).Add(x, big.NewInt(ecEncodeTries)).Cmp(params.P) >= 0 {
+		return nil, fmt.Errorf("Value out of range")
+	}
+	compressed := make([]byte, 1+(params.BitSize+7)/8)
+	compressed[0] = 2
+	for i := 0; i < ecEncodeTries; i++ {
+		x.FillBytes(compressed[1:])
+		if px, _ := elliptic.UnmarshalCompressed(e.Curve, compressed); px != nil {
+			return new(big.Int).SetBytes(compressed), nil
+		}
+		x.Add(x, bigOne)
+	}
+	return nil, fmt.Errorf("Unable to find point for value")
+}
+
+// DecodeInt impls Backend.DecodeInt.
+func (e *ECBackend) DecodeInt(v *big.Int) (*big.Int, error) {
+	x, _, err := ecPoint(e.Curve, v)
+	if err != nil {
+		return nil, err
+	}
+	return x.Rsh(x, 8), nil
+}
+
+// GenerateECKeyPair generates a key pair for the given curve with a uniformly
+// random encryption scalar.
+func GenerateECKeyPair(rnd io.Reader, curve elliptic.Curve) (*ECKeyPair, error) {
+	n := curve.Params().N
+	// Random value from 1 to n - 1, all of which are invertible since n is prime
+	enc, err := rand.Int(rnd, new(big.Int).Sub(n, bigOne))
+	if err != nil {
+		return nil, err
+	}
+	enc.Add(enc, bigOne)
+	return &ECKeyPair{Curve: curve, Enc: enc, Dec: new(big.Int).ModInverse(enc, n)}, nil
+}
+
+// EncryptInt impls Cipher.EncryptInt.
+func (k *ECKeyPair) EncryptInt(v *big.Int) *big.Int { return k.scalarMult(v, k.Enc) }
+
+// DecryptInt impls Cipher.DecryptInt.
+func (k *ECKeyPair) DecryptInt(v *big.Int) *big.Int { return k.scalarMult(v, k.Dec) }
+
+// Destroy impls Cipher.Destroy by zeroing Enc and Dec in place.
+func (k *ECKeyPair) Destroy() {
+	zeroInt(k.Enc)
+	zeroInt(k.Dec)
+}
+
+func (k *ECKeyPair) scalarMult(v *big.Int, scalar *big.Int) *big.Int {
+	x, y, err := ecPoint(k.Curve, v)
+	if err != nil {
+		return nil
+	}
+	// ScalarMult is deprecated in favor of crypto/ecdh, but ecdh only gives x
+	// back. That would be enough for the cipher alone since decoding only uses
+	// x, but proofs add points so they need the sign of y too. It also keeps
+	// ECBackend working with any prime-order curve, and for the NIST curves
+	// it is the same constant-time code ecdh uses.
+	//lint:ignore SA1019 see above
+	x, y = k.Curve.ScalarMult(x, y, scalar.Bytes())
+	return new(big.Int).SetBytes(elliptic.MarshalCompressed(k.Curve, x, y))
+}
+
+// ecPoint converts the big int form of a compressed point back to the point,
+// failing if it is not on the curve.
+func ecPoint(curve elliptic.Curve, v *big.Int) (x, y *big.Int, err error) {
+	compressed := make([]byte, 1+(curve.Params().BitSize+7)/8)
+	if v.Sign() <= 0 || v.BitLen() > len(compressed)*8 {
+		return nil, nil, fmt.Errorf("Value not a point")
+	}
+	if x, y = elliptic.UnmarshalCompressed(curve, v.FillBytes(compressed)); x == nil {
+		return nil, nil, fmt.Errorf("Value not a point")
+	}
+	return
+}
@@ -0,0 +1,171 @@
+package sra
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// keyPairVersion is the version of every key pair encoding below. Decoding
+// rejects any other version.
+const keyPairVersion = 1
+
+// keyPairPEMType is the PEM block type for the armored text encoding.
+const keyPairPEMType = "SRA KEY PAIR"
+
+// MarshalBinary impls encoding.BinaryMarshaler. The format is a version byte
+// followed by Prime, Enc, and Dec each as a 2-byte big-endian length and the
+// big-endian bytes of the value.
+func (k *KeyPair) MarshalBinary() ([]byte, error) {
+	if err := k.checkConsistent(); err != nil {
+		return nil, err
+	}
+	buf := []byte{keyPairVersion}
+	for _, v := range []*big.Int{k.Prime, k.Enc, k.Dec} {
+		b := v.Bytes()
+		if len(b) > math.MaxUint16 {
+			return nil, fmt.Errorf("Key pair value too large")
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(b)))
+		buf = append(buf, b...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary impls encoding.BinaryUnmarshaler. It fails on an unknown
+// version, non-canonical or trailing bytes, or values that are not a
+// consistent key pair.
+func (k *KeyPair) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != keyPairVersion {
+		return fmt.Errorf("Unknown key pair version")
+	}
+	data = data[1:]
+	var vals [3]*big.Int
+	for i := range vals {
+		if len(data) < 2 {
+			return fmt.Errorf("Key pair truncated")
+		}
+		size := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if size == 0 || size > len(data) {
+			return fmt.Errorf("Key pair truncated")
+		} else if data[0] == 0 {
+			return fmt.Errorf("Key pair value has leading zeros")
+		}
+		vals[i] = new(big.Int).SetBytes(data[:size])
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		return fmt.Errorf("Key pair has trailing data")
+	}
+	decoded := &KeyPair{Prime: vals[0], Enc: vals[1], Dec: vals[2], ConstantTime: k.ConstantTime}
+	if err := decoded.checkConsistent(); err != nil {
+		return err
+	}
+	*k = *decoded
+	return nil
+}
+
+// keyPairJSON is the JSON form of a key pair with values as lowercase hex.
+type keyPairJSON struct {
+	Version int    `json:"version"`
+	Prime   string `json:"prime"`
+	Enc     string `json:"enc"`
+	Dec     string `json:"dec"`
+}
+
+// MarshalJSON impls json.Marshaler.
+func (k *KeyPair) MarshalJSON() ([]byte, error) {
+	if err := k.checkConsistent(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(keyPairJSON{
+		Version: keyPairVersion,
+		Prime:   k.Prime.Text(16),
+		Enc:     k.Enc.Text(16),
+		Dec:     k.Dec.Text(16),
+	})
+}
+
+// UnmarshalJSON impls json.Unmarshaler. Unknown fields, missing fields, and
+// non-canonical hex are all rejected.
+func (k *KeyPair) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var j keyPairJSON
+	if err := dec.Decode(&j); err != nil {
+		return err
+	} else if _, err := dec.Token(); err != io.EOF {
+		// More would miss a trailing } or ], so only the end is accepted
+		return fmt.Errorf("Key pair has trailing data")
+	} else if j.Version != keyPairVersion {
+		return fmt.Errorf("Unknown key pair version")
+	}
+	var vals [3]*big.Int
+	for i, str := range []string{j.Prime, j.Enc, j.Dec} {
+		var ok bool
+		if vals[i], ok = new(big.Int).SetString(str, 16); !ok || vals[i].Text(16) != str {
+			return fmt.Errorf("Invalid key pair value")
+		}
+	}
+	decoded := &KeyPair{Prime: vals[0], Enc: vals[1], Dec: vals[2], ConstantTime: k.ConstantTime}
+	if err := decoded.checkConsistent(); err != nil {
+		return err
+	}
+	*k = *decoded
+	return nil
+}
+
+// MarshalText impls encoding.TextMarshaler with an armored (PEM) form of the
+// binary encoding that has a version header.
+func (k *KeyPair) MarshalText() ([]byte, error) {
+	b, err := k.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    keyPairPEMType,
+		Headers: map[string]string{"Version": fmt.Sprint(keyPairVersion)},
+		Bytes:   b,
+	}), nil
+}
+
+// UnmarshalText impls encoding.TextUnmarshaler for the armored form. Anything
+// other than a single block of the right type and version is rejected.
+func (k *KeyPair) UnmarshalText(text []byte) error {
+	block, rest := pem.Decode(text)
+	if block == nil || block.Type != keyPairPEMType {
+		return fmt.Errorf("No armored key pair found")
+	} else if len(bytes.TrimSpace(rest)) > 0 {
+		return fmt.Errorf("Key pair has trailing data")
+	} else if len(block.Headers) != 1 || block.Headers["Version"] != fmt.Sprint(keyPairVersion) {
+		return fmt.Errorf("Unknown key pair version")
+	}
+	return k.UnmarshalBinary(block.Bytes)
+}
+
+// checkConsistent makes sure the values are present and that Enc and Dec are
+// inverses in either the full group or the quadratic-residue subgroup of
+// Prime. This is not a full validation of the key pair.
+func (k *KeyPair) checkConsistent() error {
+	if k.Prime == nil || k.Enc == nil || k.Dec == nil {
+		return fmt.Errorf("Key pair missing values")
+	} else if k.Prime.Cmp(big.NewInt(3)) <= 0 || !k.Prime.ProbablyPrime(0) {
+		return fmt.Errorf("Key pair prime is not prime")
+	}
+	pMinus1 := new(big.Int).Sub(k.Prime, bigOne)
+	if k.Enc.Cmp(bigOne) <= 0 || k.Enc.Cmp(pMinus1) >= 0 || k.Dec.Cmp(bigOne) <= 0 || k.Dec.Cmp(pMinus1) >= 0 {
+		return fmt.Errorf("Key pair exponent out of range")
+	}
+	product := new(big.Int).Mul(k.Enc, k.Dec)
+	if new(big.Int).Mod(product, pMinus1).Cmp(bigOne) != 0 &&
+		new(big.Int).Mod(product, pMinus1.Rsh(pMinus1, 1)).Cmp(bigOne) != 0 {
+		return fmt.Errorf("Key pair exponents are not inverses")
+	}
+	return nil
+}
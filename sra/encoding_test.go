@@ -0,0 +1,113 @@
+package sra_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPairEncodingRoundTrip(t *testing.T) {
+	kp := newTestKeyPair(t)
+	// Binary
+	b, err := kp.MarshalBinary()
+	require.NoError(t, err)
+	var fromBinary sra.KeyPair
+	require.NoError(t, fromBinary.UnmarshalBinary(b))
+	require.Equal(t, kp, &fromBinary)
+	// JSON
+	j, err := json.Marshal(kp)
+	require.NoError(t, err)
+	var fromJSON sra.KeyPair
+	require.NoError(t, json.Unmarshal(j, &fromJSON))
+	require.Equal(t, kp, &fromJSON)
+	// Armored text
+	text, err := kp.MarshalText()
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(text, []byte("-----BEGIN SRA KEY PAIR-----\nVersion: 1\n")))
+	var fromText sra.KeyPair
+	require.NoError(t, fromText.UnmarshalText(text))
+	require.Equal(t, kp, &fromText)
+}
+
+func TestKeyPairEncodingCorruption(t *testing.T) {
+	kp := newTestKeyPair(t)
+	b, err := kp.MarshalBinary()
+	require.NoError(t, err)
+	// Every single-byte corruption is caught
+	for i := range b {
+		corrupt := append([]byte{}, b...)
+		corrupt[i] ^= 0x01
+		require.Error(t, new(sra.KeyPair).UnmarshalBinary(corrupt), "byte %v", i)
+	}
+	// Truncated and trailing
+	for i := 0; i < len(b); i++ {
+		require.Error(t, new(sra.KeyPair).UnmarshalBinary(b[:i]))
+	}
+	require.Error(t, new(sra.KeyPair).UnmarshalBinary(append(b, 0)))
+	// Swapped prime makes exponents inconsistent
+	other := newTestKeyPair(t)
+	require.Error(t, new(sra.KeyPair).UnmarshalBinary(rawKeyPairBinary(&sra.KeyPair{Prime: other.Prime, Enc: kp.Enc, Dec: kp.Dec})))
+
+	// JSON
+	j, err := json.Marshal(kp)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(j, &fields))
+	for _, mutate := range []func(map[string]interface{}){
+		func(m map[string]interface{}) { m["version"] = 2 },
+		func(m map[string]interface{}) { delete(m, "dec") },
+		func(m map[string]interface{}) { m["extra"] = true },
+		func(m map[string]interface{}) { m["enc"] = "00" + m["enc"].(string) },
+		func(m map[string]interface{}) { m["enc"] = "0x" + m["enc"].(string) },
+		func(m map[string]interface{}) { m["dec"] = m["enc"] },
+	} {
+		copied := map[string]interface{}{}
+		for k, v := range fields {
+			copied[k] = v
+		}
+		mutate(copied)
+		mj, err := json.Marshal(copied)
+		require.NoError(t, err)
+		require.Error(t, json.Unmarshal(mj, new(sra.KeyPair)), string(mj))
+	}
+	// Trailing data, which only gets here when called directly
+	for _, trailing := range []string{"}", "]", " {}", "x"} {
+		require.Error(t, new(sra.KeyPair).UnmarshalJSON(append(append([]byte{}, j...), trailing...)), trailing)
+	}
+	require.NoError(t, new(sra.KeyPair).UnmarshalJSON(append(append([]byte{}, j...), " \n"...)))
+
+	// Armored text
+	text, err := kp.MarshalText()
+	require.NoError(t, err)
+	for _, corrupt := range [][]byte{
+		bytes.Replace(text, []byte("SRA KEY PAIR"), []byte("RSA KEY PAIR"), -1),
+		bytes.Replace(text, []byte("Version: 1"), []byte("Version: 2"), 1),
+		bytes.Replace(text, []byte("Version: 1\n"), nil, 1),
+		append(append([]byte{}, text...), text...),
+		text[:len(text)/2],
+	} {
+		require.Error(t, new(sra.KeyPair).UnmarshalText(corrupt), string(corrupt))
+	}
+}
+
+func newTestKeyPair(t *testing.T) *sra.KeyPair {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	kp, err := sra.GenerateKeyPair(rand.Reader, sra.NewUncheckedParams(prime), sra.GenerateOptions{})
+	require.NoError(t, err)
+	return kp
+}
+
+func rawKeyPairBinary(kp *sra.KeyPair) []byte {
+	// Marshaling checks consistency too, so build the bytes by hand
+	b := []byte{1}
+	for _, v := range [][]byte{kp.Prime.Bytes(), kp.Enc.Bytes(), kp.Dec.Bytes()} {
+		b = append(b, byte(len(v)>>8), byte(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
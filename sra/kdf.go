@@ -0,0 +1,63 @@
+package sra
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// kdfReader is an HKDF-style (RFC 5869) stream. The pseudorandom key is
+// extracted once, then blocks are expanded as HMAC(prk, prev || info ||
+// counter). Unlike HKDF, the counter is 32 bits so the output is not limited to
+// 255 blocks, since generating a key may need several group-sized samples.
+type kdfReader struct {
+	mac     hash.Hash
+	info    []byte
+	counter uint32
+	prev    []byte
+	buf     []byte
+}
+
+// NewKDF returns a deterministic reader of key material derived from secret,
+// salt, and info using HMAC-SHA256. The same inputs always give the same
+// stream, and different info gives unrelated streams.
+func NewKDF(secret, salt, info []byte) io.Reader {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	return &kdfReader{mac: hmac.New(sha256.New, extract.Sum(nil)), info: info}
+}
+
+// Read impls io.Reader. It never fails.
+func (k *kdfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(k.buf) == 0 {
+			k.counter++
+			k.mac.Reset()
+			k.mac.Write(k.prev)
+			k.mac.Write(k.info)
+			k.mac.Write(binary.BigEndian.AppendUint32(nil, k.counter))
+			k.prev = k.mac.Sum(nil)
+			k.buf = k.prev
+		}
+		copied := copy(p[n:], k.buf)
+		k.buf = k.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// DeriveCipher deterministically derives a cipher from secret and info by
+// giving the backend a NewKDF stream as its randomness. Both built-in backends
+// generate keys deterministically from their reader, so the same secret and
+// info always give the same cipher. Use distinct info for every key (e.g. a
+// hand ID and card index).
+func DeriveCipher(ctx context.Context, backend Backend, secret []byte, info []byte) (Cipher, error) {
+	return backend.GenerateCipher(ctx, NewKDF(secret, nil, info))
+}
@@ -0,0 +1,50 @@
+package sra_test
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKDF(t *testing.T) {
+	secret := []byte("super secret master key material")
+	read := func(r io.Reader, n int) []byte {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		require.NoError(t, err)
+		return b
+	}
+	// Same inputs, same stream regardless of read sizes
+	r := sra.NewKDF(secret, nil, []byte("a"))
+	require.Equal(t, read(sra.NewKDF(secret, nil, []byte("a")), 100), append(read(r, 33), read(r, 67)...))
+	// Different info, salt, or secret give different streams
+	base := read(sra.NewKDF(secret, nil, []byte("a")), 32)
+	require.NotEqual(t, base, read(sra.NewKDF(secret, nil, []byte("b")), 32))
+	require.NotEqual(t, base, read(sra.NewKDF(secret, []byte("salt"), []byte("a")), 32))
+	require.NotEqual(t, base, read(sra.NewKDF(secret[1:], nil, []byte("a")), 32))
+}
+
+func TestDeriveCipher(t *testing.T) {
+	secret := []byte("super secret master key material")
+	for _, backend := range []sra.Backend{
+		&sra.SRABackend{Params: sra.MODP2048},
+		&sra.SRABackend{Params: sra.MODP2048, Options: sra.GenerateOptions{PrimeExponentBits: 64}},
+		sra.P256,
+	} {
+		c1, err := sra.DeriveCipher(context.Background(), backend, secret, []byte("card 1"))
+		require.NoError(t, err)
+		c1Again, err := sra.DeriveCipher(context.Background(), backend, secret, []byte("card 1"))
+		require.NoError(t, err)
+		c2, err := sra.DeriveCipher(context.Background(), backend, secret, []byte("card 2"))
+		require.NoError(t, err)
+		require.Equal(t, c1, c1Again)
+		require.NotEqual(t, c1, c2)
+		v, err := backend.EncodeInt(big.NewInt(5))
+		require.NoError(t, err)
+		require.Equal(t, v, c1Again.DecryptInt(c1.EncryptInt(v)))
+	}
+}
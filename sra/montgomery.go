@@ -0,0 +1,220 @@
+package sra
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// montgomery is fixed-width Montgomery arithmetic over an odd modulus. Values
+// are little-endian 64-bit limbs, always as many as the modulus has, and every
+// operation on them takes the same time regardless of their values. Only the
+// modulus, which is public, is handled by big.Int.
+type montgomery struct {
+	m []uint64
+	// -m^-1 mod 2^64
+	m0inv uint64
+	// R^2 mod m where R is 2^(64 * len(m))
+	rr []uint64
+	// R mod m, i.e. 1 in Montgomery form
+	one []uint64
+}
+
+// montgomeryCache caches the precomputed values per modulus since the same
+// shared prime is used for every key.
+var montgomeryCache sync.Map
+
+// montgomeryFor returns the cached montgomery for the odd modulus m.
+func montgomeryFor(m *big.Int) *montgomery {
+	key := string(m.Bytes())
+	if mt, ok := montgomeryCache.Load(key); ok {
+		return mt.(*montgomery)
+	}
+	mt, _ := montgomeryCache.LoadOrStore(key, newMontgomery(m))
+	return mt.(*montgomery)
+}
+
+func newMontgomery(m *big.Int) *montgomery {
+	n := (m.BitLen() + 63) / 64
+	mt := &montgomery{m: toLimbs(m, n)}
+	// Newton's method doubles the correct low bits of the inverse each time
+	inv := mt.m[0]
+	for i := 0; i < 5; i++ {
+		inv *= 2 - mt.m[0]*inv
+	}
+	mt.m0inv = -inv
+	r := new(big.Int).Lsh(bigOne, uint(64*n))
+	mt.one = toLimbs(new(big.Int).Mod(r, m), n)
+	mt.rr = toLimbs(new(big.Int).Mod(r.Mul(r, r), m), n)
+	return mt
+}
+
+// ctExp returns x^e mod m in constant time for the exponent. The base is
+// reduced mod m first. Every exponent is processed as if it were as long as the
+// modulus (or as long as e if longer) in fixed 4-bit windows with every table
+// entry read for each window, so neither the time nor the memory access
+// pattern depends on the exponent's bits.
+func ctExp(x *big.Int, e *big.Int, m *big.Int) *big.Int {
+	mt := montgomeryFor(m)
+	n := len(mt.m)
+	// Base to Montgomery form
+	base := toLimbs(new(big.Int).Mod(x, m), n)
+	mt.mul(base, base, mt.rr)
+	// Table of base^0 through base^15
+	var table [16][]uint64
+	table[0] = append([]uint64(nil), mt.one...)
+	for i := 1; i < len(table); i++ {
+		table[i] = make([]uint64, n)
+		mt.mul(table[i], table[i-1], base)
+	}
+	// Fixed-size exponent bytes
+	eBytes := make([]byte, n*8)
+	if e.BitLen() > len(eBytes)*8 {
+		eBytes = make([]byte, (e.BitLen()+7)/8)
+	}
+	e.FillBytes(eBytes)
+	acc := append([]uint64(nil), mt.one...)
+	sel := make([]uint64, n)
+	for _, b := range eBytes {
+		for _, window := range [2]byte{b >> 4, b & 0xf} {
+			for i := 0; i < 4; i++ {
+				mt.mul(acc, acc, acc)
+			}
+			// Constant-time table lookup
+			for j := range sel {
+				sel[j] = 0
+			}
+			for i, entry := range table {
+				mask := -uint64(subtle.ConstantTimeByteEq(byte(i), window))
+				for j := range sel {
+					sel[j] |= entry[j] & mask
+				}
+			}
+			mt.mul(acc, acc, sel)
+		}
+	}
+	// Out of Montgomery form by multiplying by 1
+	oneLimbs := make([]uint64, n)
+	oneLimbs[0] = 1
+	mt.mul(acc, acc, oneLimbs)
+	// Wipe what depended on the exponent
+	for i := range eBytes {
+		eBytes[i] = 0
+	}
+	for j := range sel {
+		sel[j] = 0
+	}
+	return fromLimbs(acc)
+}
+
+// ctMulAdd returns a * b + c mod m with fixed-width arithmetic whose timing
+// doesn't depend on a, b or c. The modulus must be odd and a, b and c must fit
+// in as many limbs as it has, but they don't need to be below it.
+func ctMulAdd(a, b, c, m *big.Int) *big.Int {
+	mt := montgomeryFor(m)
+	n := len(mt.m)
+	// To Montgomery form, which also reduces them since each is below R
+	x, y, z := toLimbs(a, n), toLimbs(b, n), toLimbs(c, n)
+	mt.mul(x, x, mt.rr)
+	mt.mul(y, y, mt.rr)
+	mt.mul(z, z, mt.rr)
+	mt.mul(x, x, y)
+	mt.add(x, x, z)
+	// Out of Montgomery form by multiplying by 1
+	oneLimbs := make([]uint64, n)
+	oneLimbs[0] = 1
+	mt.mul(x, x, oneLimbs)
+	// Wipe the operands
+	for j := range y {
+		y[j], z[j] = 0, 0
+	}
+	return fromLimbs(x)
+}
+
+// add sets z to x + y mod m for x and y below m. The z slice may be the same
+// as x or y.
+func (mt *montgomery) add(z, x, y []uint64) {
+	n := len(mt.m)
+	sum := make([]uint64, n)
+	var carry uint64
+	for j := 0; j < n; j++ {
+		sum[j], carry = bits.Add64(x[j], y[j], carry)
+	}
+	// Now sum < 2m, so subtract m and keep the result unless it borrowed
+	var borrow uint64
+	for j := 0; j < n; j++ {
+		z[j], borrow = bits.Sub64(sum[j], mt.m[j], borrow)
+	}
+	_, borrow = bits.Sub64(carry, 0, borrow)
+	keep := -borrow
+	for j := 0; j < n; j++ {
+		z[j] = (sum[j] & keep) | (z[j] &^ keep)
+	}
+}
+
+// mul sets z to x * y * R^-1 mod m using the CIOS method. The z slice may be
+// the same as x or y.
+func (mt *montgomery) mul(z, x, y []uint64) {
+	n := len(mt.m)
+	t := make([]uint64, n+2)
+	for i := 0; i < n; i++ {
+		// t += x * y[i]
+		var c uint64
+		for j := 0; j < n; j++ {
+			c, t[j] = mulAdd(x[j], y[i], t[j], c)
+		}
+		t[n], c = bits.Add64(t[n], c, 0)
+		t[n+1] = c
+		// t = (t + u * m) / 2^64 where u makes the low limb 0
+		u := t[0] * mt.m0inv
+		c, _ = mulAdd(u, mt.m[0], t[0], 0)
+		for j := 1; j < n; j++ {
+			c, t[j-1] = mulAdd(u, mt.m[j], t[j], c)
+		}
+		t[n-1], c = bits.Add64(t[n], c, 0)
+		t[n] = t[n+1] + c
+	}
+	// Now t < 2m, so subtract m and keep the result unless it borrowed
+	var borrow uint64
+	for j := 0; j < n; j++ {
+		z[j], borrow = bits.Sub64(t[j], mt.m[j], borrow)
+	}
+	_, borrow = bits.Sub64(t[n], 0, borrow)
+	keep := -borrow
+	for j := 0; j < n; j++ {
+		z[j] = (t[j] & keep) | (z[j] &^ keep)
+	}
+}
+
+// mulAdd returns the high and low words of a * b + c + d, which can't
+// overflow.
+func mulAdd(a, b, c, d uint64) (hi, lo uint64) {
+	hi, lo = bits.Mul64(a, b)
+	var carry uint64
+	lo, carry = bits.Add64(lo, c, 0)
+	hi += carry
+	lo, carry = bits.Add64(lo, d, 0)
+	hi += carry
+	return
+}
+
+// toLimbs converts v, which must fit, to n little-endian limbs.
+func toLimbs(v *big.Int, n int) []uint64 {
+	buf := v.FillBytes(make([]byte, n*8))
+	limbs := make([]uint64, n)
+	for i := range limbs {
+		limbs[i] = binary.BigEndian.Uint64(buf[len(buf)-8*(i+1):])
+	}
+	return limbs
+}
+
+// fromLimbs converts little-endian limbs to a big int.
+func fromLimbs(limbs []uint64) *big.Int {
+	buf := make([]byte, len(limbs)*8)
+	for i, limb := range limbs {
+		binary.BigEndian.PutUint64(buf[len(buf)-8*(i+1):], limb)
+	}
+	return new(big.Int).SetBytes(buf)
+}
@@ -0,0 +1,67 @@
+package sra_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantTimeMatchesExp(t *testing.T) {
+	prime256, err := rand.Prime(rand.Reader, 256)
+	require.NoError(t, err)
+	// An odd-sized prime to make sure partial limbs work
+	oddPrime, err := rand.Prime(rand.Reader, 1000)
+	require.NoError(t, err)
+	for _, prime := range []*big.Int{sra.MODP2048.P, prime256, oddPrime} {
+		pMinus1 := new(big.Int).Sub(prime, big.NewInt(1))
+		exps := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(65537), pMinus1}
+		vals := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), pMinus1, prime, new(big.Int).Lsh(prime, 3)}
+		for i := 0; i < 5; i++ {
+			e, err := rand.Int(rand.Reader, prime)
+			require.NoError(t, err)
+			v, err := rand.Int(rand.Reader, prime)
+			require.NoError(t, err)
+			exps, vals = append(exps, e), append(vals, v)
+		}
+		for _, e := range exps {
+			for _, v := range vals {
+				kp := &sra.KeyPair{Prime: prime, Enc: e, Dec: e, ConstantTime: true}
+				// Values outside the group are refused before exponentiating
+				if !kp.Valid(v) {
+					require.Nil(t, kp.EncryptInt(v))
+					continue
+				}
+				expected := new(big.Int).Exp(v, e, prime)
+				require.Zero(t, expected.Cmp(kp.EncryptInt(v)), "%v^%v mod %v", v, e, prime)
+				require.Zero(t, expected.Cmp(kp.DecryptInt(v)))
+			}
+		}
+	}
+}
+
+func TestConstantTimeKeyPair(t *testing.T) {
+	params := sra.MODP2048
+	kp, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{ConstantTime: true})
+	require.NoError(t, err)
+	require.True(t, kp.ConstantTime)
+	card, err := params.Encode(big.NewInt(10))
+	require.NoError(t, err)
+	encrypted := kp.EncryptInt(card)
+	require.Equal(t, card, kp.DecryptInt(encrypted))
+	require.Equal(t, encrypted, (&sra.KeyPair{Prime: kp.Prime, Enc: kp.Enc, Dec: kp.Dec}).EncryptInt(card))
+	// Re-encrypting decrypts and encrypts separately but gives the same result
+	other, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{ConstantTime: true})
+	require.NoError(t, err)
+	vals := []*big.Int{encrypted}
+	require.NoError(t, sra.ReencryptInts(kp, []sra.Cipher{other}, vals, 1))
+	require.Equal(t, other.EncryptInt(card), vals[0])
+	// Kept across unmarshal
+	b, err := kp.MarshalBinary()
+	require.NoError(t, err)
+	decoded := &sra.KeyPair{ConstantTime: true}
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	require.Equal(t, kp, decoded)
+}
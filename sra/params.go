@@ -0,0 +1,166 @@
+package sra
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Params are the group parameters shared by every player. Use one of the
+// named groups or NewParams instead of building these directly so that the
+// prime is vetted and the cached values are set.
+type Params struct {
+	// Name is the name of the group if it is a named one.
+	Name string
+	// P is the shared prime.
+	P *big.Int
+	// PMinus1 is P - 1, the order of the full group Z*_p.
+	PMinus1 *big.Int
+	// Q is (P - 1) / 2 if P is a safe prime, or nil otherwise. When set, keys
+	// work in the quadratic-residue subgroup of order Q, values must be
+	// encoded with Encode before encryption, and key pairs refuse any value
+	// outside the subgroup.
+	Q *big.Int
+}
+
+// MinPrimeBits is the minimum size of the prime accepted by NewParams.
+const MinPrimeBits = 2048
+
+// Named groups are the safe primes from RFC 3526 (MODP groups 14, 15, and 16).
+var (
+	MODP2048 = mustNamedParams("modp2048", modp2048Hex)
+	MODP3072 = mustNamedParams("modp3072", modp3072Hex)
+	MODP4096 = mustNamedParams("modp4096", modp4096Hex)
+)
+
+var namedParams = map[string]*Params{
+	MODP2048.Name: MODP2048,
+	MODP3072.Name: MODP3072,
+	MODP4096.Name: MODP4096,
+}
+
+// NamedParams returns the named group for the given name (e.g. "modp2048").
+func NamedParams(name string) (*Params, error) {
+	if params := namedParams[name]; params != nil {
+		return params, nil
+	}
+	return nil, fmt.Errorf("Unknown group %v", name)
+}
+
+// NewParams validates the given prime with ValidatePrime using MinPrimeBits and
+// returns params for it.
+func NewParams(p *big.Int) (*Params, error) {
+	if err := ValidatePrime(p, MinPrimeBits); err != nil {
+		return nil, err
+	}
+	return NewUncheckedParams(p), nil
+}
+
+// NewUncheckedParams returns params for the given prime without validating it.
+// If the prime happens to be a safe prime, Q is set. This is only meant for
+// testing and evaluating smaller or weaker primes.
+func NewUncheckedParams(p *big.Int) *Params {
+	params := &Params{P: p, PMinus1: new(big.Int).Sub(p, bigOne)}
+	if IsSafePrime(p) {
+		params.Q = new(big.Int).Rsh(p, 1)
+	}
+	safePrimes.Store(string(p.Bytes()), params.Q != nil)
+	return params
+}
+
+// ValidatePrime confirms p is a safe prime of at least minBits.
+func ValidatePrime(p *big.Int, minBits int) error {
+	if p.BitLen() < minBits {
+		return fmt.Errorf("Prime is %v bits, need at least %v", p.BitLen(), minBits)
+	} else if !p.ProbablyPrime(20) {
+		return fmt.Errorf("Value is not prime")
+	} else if !new(big.Int).Rsh(p, 1).ProbablyPrime(20) {
+		return fmt.Errorf("Prime is not a safe prime")
+	}
+	return nil
+}
+
+// Order is the order of the group the keys work in. Key exponents are inverses
+// mod this. It is Q if set, or PMinus1 otherwise.
+func (p *Params) Order() *big.Int {
+	if p.Q != nil {
+		return p.Q
+	}
+	return p.PMinus1
+}
+
+// Encode maps v to a value that can be encrypted with these params. If Q is
+// set, v must be from 1 to Q and it is mapped with EncodeQR. Otherwise, v must
+// be from 1 to P - 1 and is returned as is.
+func (p *Params) Encode(v *big.Int) (*big.Int, error) {
+	if p.Q != nil {
+		return EncodeQR(p.P, v)
+	} else if v.Sign() <= 0 || v.Cmp(p.P) >= 0 {
+		return nil, fmt.Errorf("Value out of range")
+	}
+	return new(big.Int).Set(v), nil
+}
+
+// Decode is the reverse of Encode.
+func (p *Params) Decode(v *big.Int) (*big.Int, error) {
+	if p.Q != nil {
+		return DecodeQR(p.P, v)
+	} else if v.Sign() <= 0 || v.Cmp(p.P) >= 0 {
+		return nil, fmt.Errorf("Value out of range")
+	}
+	return new(big.Int).Set(v), nil
+}
+
+// mustNamedParams builds trusted params for a well-known safe prime without
+// the costly validation. The named groups are validated in tests instead.
+func mustNamedParams(name string, hex string) *Params {
+	p, ok := new(big.Int).SetString(hex, 16)
+	if !ok {
+		panic("Invalid prime for " + name)
+	}
+	safePrimes.Store(string(p.Bytes()), true)
+	return &Params{
+		Name:    name,
+		P:       p,
+		PMinus1: new(big.Int).Sub(p, bigOne),
+		Q:       new(big.Int).Rsh(p, 1),
+	}
+}
+
+const modp2048Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+	"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+const modp3072Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+const modp4096Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+	"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+	"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+	"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+	"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+	"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+	"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+	"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+	"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF"
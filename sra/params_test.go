@@ -0,0 +1,48 @@
+package sra_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/internal/testutil"
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedParams(t *testing.T) {
+	for _, name := range []string{"modp2048", "modp3072", "modp4096"} {
+		params, err := sra.NamedParams(name)
+		require.NoError(t, err)
+		require.Equal(t, name, params.Name)
+		// Named groups skip validation at init, so confirm them here
+		require.NoError(t, sra.ValidatePrime(params.P, sra.MinPrimeBits))
+		require.Zero(t, params.PMinus1.Cmp(new(big.Int).Sub(params.P, big.NewInt(1))))
+		require.Zero(t, params.Q.Cmp(new(big.Int).Rsh(params.P, 1)))
+		require.Equal(t, params.Q, params.Order())
+	}
+	_, err := sra.NamedParams("modp1024")
+	require.Error(t, err)
+}
+
+func TestNewParamsValidation(t *testing.T) {
+	// Good
+	params, err := sra.NewParams(sra.MODP2048.P)
+	require.NoError(t, err)
+	require.NotNil(t, params.Q)
+	// Too small
+	safePrime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	_, err = sra.NewParams(safePrime)
+	require.Error(t, err)
+	require.NoError(t, sra.ValidatePrime(safePrime, 256))
+	// Not prime
+	_, err = sra.NewParams(new(big.Int).Add(sra.MODP2048.P, big.NewInt(2)))
+	require.Error(t, err)
+	// Not a safe prime
+	prime := testutil.NewUnsafePrime(t, 256)
+	require.Error(t, sra.ValidatePrime(prime, 256))
+	unchecked := sra.NewUncheckedParams(prime)
+	require.Nil(t, unchecked.Q)
+	require.Equal(t, unchecked.PMinus1, unchecked.Order())
+}
@@ -0,0 +1,149 @@
+package sra
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+)
+
+// KeyPool pre-generates ciphers from a backend in a background goroutine so
+// they are ready when needed (e.g. while players are idle between hands). Keys
+// are always generated with crypto/rand. The pool refills up to its target
+// size whenever a key is taken and never holds more than its max size.
+type KeyPool struct {
+	backend Backend
+	max     int
+	cancel  context.CancelFunc
+	done    chan struct{}
+	wake    chan struct{}
+
+	mu     sync.Mutex
+	target int
+	keys   []Cipher
+	err    error
+}
+
+// NewKeyPool creates a pool for the backend and starts filling it up to target
+// keys. The max is the hard cap of keys held at once and must be at least
+// target. Close must be called when done with the pool.
+func NewKeyPool(backend Backend, target int, max int) (*KeyPool, error) {
+	if target < 1 || max < target {
+		return nil, fmt.Errorf("Invalid pool size")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &KeyPool{
+		backend: backend,
+		target:  target,
+		max:     max,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		keys:    make([]Cipher, 0, max),
+	}
+	go p.fill(ctx)
+	return p, nil
+}
+
+// fill generates keys until the pool is at its target, then waits until woken
+// by Take. It stops on close or on the first key generation error.
+func (p *KeyPool) fill(ctx context.Context) {
+	defer close(p.done)
+	for {
+		p.mu.Lock()
+		need := len(p.keys) < p.target
+		p.mu.Unlock()
+		if !need {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.wake:
+				continue
+			}
+		}
+		key, err := p.backend.GenerateCipher(ctx, rand.Reader)
+		p.mu.Lock()
+		if err != nil {
+			if ctx.Err() == nil {
+				p.err = err
+			}
+			p.mu.Unlock()
+			return
+		}
+		if len(p.keys) >= p.max {
+			key.Destroy()
+		} else {
+			p.keys = append(p.keys, key)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Take removes and returns a key from the pool, or returns false if the pool
+// is empty or closed. The caller then owns the key.
+func (p *KeyPool) Take() (Cipher, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) == 0 {
+		return nil, false
+	}
+	key := p.keys[len(p.keys)-1]
+	p.keys[len(p.keys)-1] = nil
+	p.keys = p.keys[:len(p.keys)-1]
+	// Ask for a refill without blocking
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return key, true
+}
+
+// SetTarget changes the number of keys the pool fills up to (e.g. to prepare
+// for a bigger deck). It is kept from 1 to the max size given to NewKeyPool. A
+// smaller target doesn't drop keys already in the pool.
+func (p *KeyPool) SetTarget(target int) {
+	if target < 1 {
+		target = 1
+	} else if target > p.max {
+		target = p.max
+	}
+	p.mu.Lock()
+	p.target = target
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Backend is the backend the pool's keys are generated from.
+func (p *KeyPool) Backend() Backend { return p.backend }
+
+// Len is the number of keys currently in the pool.
+func (p *KeyPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys)
+}
+
+// Err is the key generation error that stopped the pool from filling, if any.
+// Take keeps returning the remaining keys after that.
+func (p *KeyPool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Close stops filling the pool, waits for the background goroutine to end, and
+// destroys every key left in the pool. Keys already taken are not affected.
+func (p *KeyPool) Close() {
+	p.cancel()
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, key := range p.keys {
+		key.Destroy()
+		p.keys[i] = nil
+	}
+	p.keys = nil
+}
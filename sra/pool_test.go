@@ -0,0 +1,56 @@
+package sra_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPool(t *testing.T) {
+	_, err := sra.NewKeyPool(sra.P256, 5, 4)
+	require.Error(t, err)
+
+	pool, err := sra.NewKeyPool(sra.P256, 5, 8)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return pool.Len() == 5 }, 5*time.Second, time.Millisecond)
+	// Never more than the target
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 5, pool.Len())
+
+	// Taking refills
+	taken := make([]sra.Cipher, 3)
+	for i := range taken {
+		var ok bool
+		taken[i], ok = pool.Take()
+		require.True(t, ok)
+	}
+	require.Eventually(t, func() bool { return pool.Len() == 5 }, 5*time.Second, time.Millisecond)
+
+	// Target is capped at the max
+	pool.SetTarget(100)
+	require.Eventually(t, func() bool { return pool.Len() == 8 }, 5*time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 8, pool.Len())
+	// And never below 1
+	for i := 0; i < 8; i++ {
+		_, ok := pool.Take()
+		require.True(t, ok)
+	}
+	pool.SetTarget(-5)
+	require.Eventually(t, func() bool { return pool.Len() == 1 }, 5*time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 1, pool.Len())
+	require.Equal(t, sra.P256, pool.Backend())
+
+	// Closing empties the pool but doesn't touch what was taken
+	pool.Close()
+	_, ok := pool.Take()
+	require.False(t, ok)
+	require.Zero(t, pool.Len())
+	require.NoError(t, pool.Err())
+	for _, key := range taken {
+		require.NotZero(t, key.(*sra.ECKeyPair).Enc.Sign())
+	}
+}
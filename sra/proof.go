@@ -0,0 +1,259 @@
+package sra
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// DecryptProof is a non-interactive Chaum-Pedersen proof that a value was
+// decrypted with the same exponent (or scalar) as the one behind a commitment
+// from Prover.Commit, without revealing it.
+type DecryptProof struct {
+	// C is the Fiat-Shamir challenge.
+	C *big.Int
+	// S is the response.
+	S *big.Int
+}
+
+// Prover commits to the decryption keys of ciphers and proves decryptions were
+// done with them. A commitment is the group generator raised to (or multiplied
+// by) the decryption exponent, so it can be published without giving up the
+// key. Get one with Backend.Prover.
+type Prover struct {
+	group proofGroup
+}
+
+// proofGroup is a prime-order group that proofs can be done in. Elements are
+// in the same big int form the ciphers use.
+type proofGroup interface {
+	// order is the prime order of the group.
+	order() *big.Int
+	// generator is a generator of the group.
+	generator() *big.Int
+	// size is the byte size of an element for hashing.
+	size() int
+	// valid reports whether v is an element of the group.
+	valid(v *big.Int) bool
+	// exp returns v to the e, or nil on failure.
+	exp(v *big.Int, e *big.Int) *big.Int
+	// mul returns the group operation on a and b, or nil on failure.
+	mul(a *big.Int, b *big.Int) *big.Int
+	// decExponent returns the decryption exponent of c if it is for this group.
+	decExponent(c Cipher) (*big.Int, error)
+	// secretExp is exp for a secret e of c (its decryption exponent or a proof
+	// nonce), done in constant time if c asks for it.
+	secretExp(c Cipher, v *big.Int, e *big.Int) *big.Int
+	// secretResponse returns k + ch * dec mod the order for the proof nonce k,
+	// challenge ch, and decryption exponent dec of c, done in constant time if
+	// c asks for it.
+	secretResponse(c Cipher, k, ch, dec *big.Int) *big.Int
+}
+
+// Prover impls Backend.Prover. It is nil if Params has no Q since proofs need a
+// prime-order group.
+func (s *SRABackend) Prover() *Prover {
+	if s.Params.Q == nil {
+		return nil
+	}
+	return &Prover{group: qrGroup{s.Params}}
+}
+
+// Prover impls Backend.Prover.
+func (e *ECBackend) Prover() *Prover { return &Prover{group: ecGroup{e.Curve}} }
+
+// Commit returns the public commitment to the decryption key of c.
+func (p *Prover) Commit(c Cipher) (*big.Int, error) {
+	dec, err := p.group.decExponent(c)
+	if err != nil {
+		return nil, err
+	}
+	return p.group.secretExp(c, p.group.generator(), dec), nil
+}
+
+// ProveDecrypt decrypts v with c and returns the result along with a proof
+// that it was decrypted with the key behind c's commitment. The rnd reader is
+// used for the proof nonce.
+func (p *Prover) ProveDecrypt(rnd io.Reader, c Cipher, v *big.Int) (*big.Int, *DecryptProof, error) {
+	dec, err := p.group.decExponent(c)
+	if err != nil {
+		return nil, nil, err
+	} else if !p.group.valid(v) {
+		return nil, nil, fmt.Errorf("Value not in group")
+	}
+	decrypted := c.DecryptInt(v)
+	commitment := p.group.secretExp(c, p.group.generator(), dec)
+	// Nonce from 1 to order - 1
+	k, err := rand.Int(rnd, new(big.Int).Sub(p.group.order(), bigOne))
+	if err != nil {
+		return nil, nil, err
+	}
+	k.Add(k, bigOne)
+	proof := &DecryptProof{
+		C: p.challenge(commitment, v, decrypted,
+			p.group.secretExp(c, p.group.generator(), k), p.group.secretExp(c, v, k)),
+	}
+	proof.S = p.group.secretResponse(c, k, proof.C, dec)
+	return decrypted, proof, nil
+}
+
+// VerifyDecrypt checks that decrypted is v decrypted with the key behind
+// commitment according to proof.
+func (p *Prover) VerifyDecrypt(commitment, v, decrypted *big.Int, proof *DecryptProof) error {
+	order := p.group.order()
+	if proof == nil || proof.C == nil || proof.S == nil ||
+		proof.C.Sign() < 0 || proof.C.Cmp(order) >= 0 || proof.S.Sign() < 0 || proof.S.Cmp(order) >= 0 {
+		return fmt.Errorf("Invalid proof")
+	}
+	for _, e := range []*big.Int{commitment, v, decrypted} {
+		if e == nil || !p.group.valid(e) {
+			return fmt.Errorf("Value not in group")
+		}
+	}
+	// Rebuild the nonce commitments as g^s / commitment^c and v^s / decrypted^c
+	negC := new(big.Int).Sub(order, proof.C)
+	negC.Mod(negC, order)
+	a := p.group.mul(p.group.exp(p.group.generator(), proof.S), p.group.exp(commitment, negC))
+	b := p.group.mul(p.group.exp(v, proof.S), p.group.exp(decrypted, negC))
+	if a == nil || b == nil || p.challenge(commitment, v, decrypted, a, b).Cmp(proof.C) != 0 {
+		return fmt.Errorf("Decryption proof failed")
+	}
+	return nil
+}
+
+// challenge is the Fiat-Shamir challenge hash of every proof element, reduced
+// by the group order.
+func (p *Prover) challenge(vals ...*big.Int) *big.Int {
+	h := sha256.New()
+	h.Write([]byte("sra decrypt proof v1"))
+	buf := make([]byte, p.group.size())
+	for _, v := range append([]*big.Int{p.group.generator()}, vals...) {
+		h.Write(v.FillBytes(buf))
+	}
+	c := new(big.Int).SetBytes(h.Sum(nil))
+	return c.Mod(c, p.group.order())
+}
+
+// qrGroup is the quadratic-residue subgroup of a safe prime, generated by 4.
+type qrGroup struct{ params *Params }
+
+var bigFour = big.NewInt(4)
+
+func (q qrGroup) order() *big.Int     { return q.params.Q }
+func (q qrGroup) generator() *big.Int { return bigFour }
+func (q qrGroup) size() int           { return (q.params.P.BitLen() + 7) / 8 }
+
+func (q qrGroup) valid(v *big.Int) bool {
+	return v.Sign() > 0 && v.Cmp(q.params.P) < 0 && big.Jacobi(v, q.params.P) == 1
+}
+
+func (q qrGroup) exp(v *big.Int, e *big.Int) *big.Int {
+	return new(big.Int).Exp(v, e, q.params.P)
+}
+
+// secretExp impls proofGroup.secretExp using ctExp for constant-time key
+// pairs.
+func (q qrGroup) secretExp(c Cipher, v *big.Int, e *big.Int) *big.Int {
+	if kp, ok := c.(*KeyPair); ok && kp.ConstantTime {
+		return ctExp(v, e, q.params.P)
+	}
+	return q.exp(v, e)
+}
+
+// secretResponse impls proofGroup.secretResponse using ctMulAdd for
+// constant-time key pairs.
+func (q qrGroup) secretResponse(c Cipher, k, ch, dec *big.Int) *big.Int {
+	if kp, ok := c.(*KeyPair); ok && kp.ConstantTime {
+		// Generated keys are always below the order, so this is only for keys
+		// from elsewhere that may not fit
+		if dec.Cmp(q.params.Q) >= 0 {
+			dec = new(big.Int).Mod(dec, q.params.Q)
+		}
+		return ctMulAdd(ch, dec, k, q.params.Q)
+	}
+	return bigResponse(k, ch, dec, q.params.Q)
+}
+
+// bigResponse is k + ch * dec mod order with big.Int arithmetic.
+func bigResponse(k, ch, dec, order *big.Int) *big.Int {
+	s := new(big.Int).Mul(ch, dec)
+	return s.Add(s, k).Mod(s, order)
+}
+
+func (q qrGroup) mul(a *big.Int, b *big.Int) *big.Int {
+	if a == nil || b == nil {
+		return nil
+	}
+	ret := new(big.Int).Mul(a, b)
+	return ret.Mod(ret, q.params.P)
+}
+
+func (q qrGroup) decExponent(c Cipher) (*big.Int, error) {
+	if kp, ok := c.(*KeyPair); ok && kp.Prime.Cmp(q.params.P) == 0 {
+		return kp.Dec, nil
+	}
+	return nil, fmt.Errorf("Cipher not for these params")
+}
+
+// ecGroup is the group of points on a prime-order curve.
+type ecGroup struct{ curve elliptic.Curve }
+
+func (e ecGroup) order() *big.Int { return e.curve.Params().N }
+func (e ecGroup) size() int       { return 1 + (e.curve.Params().BitSize+7)/8 }
+
+func (e ecGroup) generator() *big.Int {
+	params := e.curve.Params()
+	return new(big.Int).SetBytes(elliptic.MarshalCompressed(e.curve, params.Gx, params.Gy))
+}
+
+func (e ecGroup) valid(v *big.Int) bool {
+	_, _, err := ecPoint(e.curve, v)
+	return err == nil
+}
+
+func (e ecGroup) exp(v *big.Int, scalar *big.Int) *big.Int {
+	return (&ECKeyPair{Curve: e.curve}).scalarMult(v, scalar)
+}
+
+// secretExp impls proofGroup.secretExp. It is the same as exp since scalar
+// multiplication on the crypto/elliptic curves is already constant time.
+func (e ecGroup) secretExp(c Cipher, v *big.Int, scalar *big.Int) *big.Int { return e.exp(v, scalar) }
+
+// secretResponse impls proofGroup.secretResponse. EC key pairs have no
+// constant-time option.
+func (e ecGroup) secretResponse(c Cipher, k, ch, dec *big.Int) *big.Int {
+	return bigResponse(k, ch, dec, e.order())
+}
+
+func (e ecGroup) mul(a *big.Int, b *big.Int) *big.Int {
+	if a == nil || b == nil {
+		return nil
+	}
+	ax, ay, err := ecPoint(e.curve, a)
+	if err != nil {
+		return nil
+	}
+	bx, by, err := ecPoint(e.curve, b)
+	if err != nil {
+		return nil
+	}
+	// There is no replacement for the deprecated Add in crypto/ecdh, see
+	// ECKeyPair.scalarMult
+	//lint:ignore SA1019 see above
+	x, y := e.curve.Add(ax, ay, bx, by)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		// Point at infinity
+		return nil
+	}
+	return new(big.Int).SetBytes(elliptic.MarshalCompressed(e.curve, x, y))
+}
+
+func (e ecGroup) decExponent(c Cipher) (*big.Int, error) {
+	if kp, ok := c.(*ECKeyPair); ok && kp.Curve == e.curve {
+		return kp.Dec, nil
+	}
+	return nil, fmt.Errorf("Cipher not for this curve")
+}
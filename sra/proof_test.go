@@ -0,0 +1,85 @@
+package sra_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/internal/testutil"
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptProof(t *testing.T) {
+	safePrime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.NewUncheckedParams(safePrime)}, sra.P256} {
+		prover := backend.Prover()
+		require.NotNil(t, prover)
+		key, err := backend.GenerateCipher(context.Background(), rand.Reader)
+		require.NoError(t, err)
+		otherKey, err := backend.GenerateCipher(context.Background(), rand.Reader)
+		require.NoError(t, err)
+		commitment, err := prover.Commit(key)
+		require.NoError(t, err)
+		otherCommitment, err := prover.Commit(otherKey)
+		require.NoError(t, err)
+		card, err := backend.EncodeInt(big.NewInt(5))
+		require.NoError(t, err)
+		encrypted := otherKey.EncryptInt(key.EncryptInt(card))
+
+		// Valid proof
+		decrypted, proof, err := prover.ProveDecrypt(rand.Reader, key, encrypted)
+		require.NoError(t, err)
+		require.Equal(t, key.DecryptInt(encrypted), decrypted)
+		require.NoError(t, prover.VerifyDecrypt(commitment, encrypted, decrypted, proof))
+
+		// Wrong commitment, wrong result, wrong input, or tampered proof
+		require.Error(t, prover.VerifyDecrypt(otherCommitment, encrypted, decrypted, proof))
+		require.Error(t, prover.VerifyDecrypt(commitment, encrypted, otherKey.DecryptInt(encrypted), proof))
+		require.Error(t, prover.VerifyDecrypt(commitment, card, decrypted, proof))
+		require.Error(t, prover.VerifyDecrypt(commitment, encrypted, decrypted,
+			&sra.DecryptProof{C: proof.C, S: new(big.Int).Add(proof.S, big.NewInt(1))}))
+		require.Error(t, prover.VerifyDecrypt(commitment, encrypted, decrypted, nil))
+	}
+
+	// No proofs without a prime-order group
+	prime := testutil.NewUnsafePrime(t, 256)
+	require.Nil(t, (&sra.SRABackend{Params: sra.NewUncheckedParams(prime)}).Prover())
+}
+
+func TestConstantTimeDecryptProof(t *testing.T) {
+	params := sra.MODP2048
+	prover := (&sra.SRABackend{Params: params}).Prover()
+	key, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+	require.NoError(t, err)
+	ctKey := &sra.KeyPair{Prime: key.Prime, Enc: key.Enc, Dec: key.Dec, ConstantTime: true}
+	card, err := params.Encode(big.NewInt(7))
+	require.NoError(t, err)
+	encrypted := key.EncryptInt(card)
+	// Same nonce randomness for both
+	nonceBytes := make([]byte, 1024)
+	_, err = rand.Read(nonceBytes)
+	require.NoError(t, err)
+
+	commitment, err := prover.Commit(key)
+	require.NoError(t, err)
+	ctCommitment, err := prover.Commit(ctKey)
+	require.NoError(t, err)
+	require.Equal(t, commitment, ctCommitment)
+	decrypted, proof, err := prover.ProveDecrypt(bytes.NewReader(nonceBytes), key, encrypted)
+	require.NoError(t, err)
+	ctDecrypted, ctProof, err := prover.ProveDecrypt(bytes.NewReader(nonceBytes), ctKey, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, card, ctDecrypted)
+	require.Equal(t, decrypted, ctDecrypted)
+	require.Equal(t, proof, ctProof)
+	require.NoError(t, prover.VerifyDecrypt(commitment, encrypted, ctDecrypted, ctProof))
+	// Even if the decryption exponent isn't below the order
+	bigDecKey := &sra.KeyPair{Prime: key.Prime, Enc: key.Enc, Dec: new(big.Int).Add(key.Dec, params.Q), ConstantTime: true}
+	_, bigDecProof, err := prover.ProveDecrypt(bytes.NewReader(nonceBytes), bigDecKey, encrypted)
+	require.NoError(t, err)
+	require.Equal(t, proof, bigDecProof)
+}
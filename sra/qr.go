@@ -0,0 +1,102 @@
+package sra
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// Encrypting in the full group Z*_p keeps the Legendre symbol of the value
+// intact since every encryption exponent is odd. So anyone can tell whether a
+// ciphertext came from a quadratic residue or not, which leaks about half a
+// bit per card. Working in the quadratic-residue subgroup of a safe prime
+// p = 2q + 1 fixes this since every value in there has the same symbol.
+
+// IsSafePrime returns true if p and (p-1)/2 are both (probably) prime.
+func IsSafePrime(p *big.Int) bool {
+	if p.Sign() <= 0 || p.Bit(0) == 0 || !p.ProbablyPrime(20) {
+		return false
+	}
+	return new(big.Int).Rsh(p, 1).ProbablyPrime(20)
+}
+
+// safePrimes caches whether a key pair's prime is a safe prime, keyed by the
+// prime's bytes, since checking is slow and the same shared prime is used for
+// every key. Params store what they already know here.
+var safePrimes sync.Map
+
+// isSafePrimeCached is IsSafePrime cached in safePrimes.
+func isSafePrimeCached(p *big.Int) bool {
+	key := string(p.Bytes())
+	if safe, ok := safePrimes.Load(key); ok {
+		return safe.(bool)
+	}
+	safe := IsSafePrime(p)
+	safePrimes.Store(key, safe)
+	return safe
+}
+
+// ErrSafePrimeExhausted is returned by GenerateSafePrimeContext when no safe
+// prime was found within the maximum number of attempts.
+var ErrSafePrimeExhausted = errors.New("Safe prime attempts exhausted")
+
+// GenerateSafePrime generates a safe prime p = 2q + 1 of the given bits where q
+// is also prime. This is GenerateSafePrimeContext with a background context and
+// the default maximum attempts.
+func GenerateSafePrime(rnd io.Reader, bits int) (*big.Int, error) {
+	return GenerateSafePrimeContext(context.Background(), rnd, bits, 0)
+}
+
+// GenerateSafePrimeContext generates a safe prime like GenerateSafePrime but
+// stops when ctx is done, returning its error, or after maxAttempts primes q
+// were tried, returning ErrSafePrimeExhausted. If maxAttempts is zero, it is 8
+// times bits which is far more than the roughly bits / 4 attempts usually
+// needed.
+func GenerateSafePrimeContext(ctx context.Context, rnd io.Reader, bits int, maxAttempts int) (*big.Int, error) {
+	if maxAttempts == 0 {
+		maxAttempts = 8 * bits
+	}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(rnd, bits-1)
+		if err != nil {
+			return nil, err
+		}
+		p := new(big.Int).Lsh(q, 1)
+		if p.Add(p, bigOne).ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+	return nil, ErrSafePrimeExhausted
+}
+
+// EncodeQR maps v, which must be from 1 to (p-1)/2, to a quadratic residue mod
+// the given safePrime. This works because -1 is not a residue for safe primes,
+// so exactly one of v and p-v is. Usually Params.Encode is used instead.
+func EncodeQR(safePrime *big.Int, v *big.Int) (*big.Int, error) {
+	if v.Sign() <= 0 || v.Cmp(new(big.Int).Rsh(safePrime, 1)) > 0 {
+		return nil, fmt.Errorf("Value out of range")
+	}
+	if big.Jacobi(v, safePrime) == 1 {
+		return new(big.Int).Set(v), nil
+	}
+	return new(big.Int).Sub(safePrime, v), nil
+}
+
+// DecodeQR is the reverse of EncodeQR. It fails if v is not a quadratic
+// residue mod safePrime.
+func DecodeQR(safePrime *big.Int, v *big.Int) (*big.Int, error) {
+	if v.Sign() <= 0 || v.Cmp(safePrime) >= 0 || big.Jacobi(v, safePrime) != 1 {
+		return nil, fmt.Errorf("Value not a quadratic residue")
+	}
+	if v.Cmp(new(big.Int).Rsh(safePrime, 1)) <= 0 {
+		return new(big.Int).Set(v), nil
+	}
+	return new(big.Int).Sub(safePrime, v), nil
+}
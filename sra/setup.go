@@ -0,0 +1,203 @@
+package sra
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// SetupSeedSize is the size in bytes of each player's seed in a ParamsSetup.
+const SetupSeedSize = 32
+
+// ParamsSetup is one player's side of jointly generating params so that no
+// single player picks the prime (and possibly a weak or trapdoored one). The
+// protocol is:
+//
+//  1. Every player creates a setup with NewParamsSetup and publishes its
+//     Commitment.
+//  2. Once a player has every commitment, it publishes its seed from Reveal.
+//  3. Once a player has every seed, it calls Params which checks every seed
+//     against its commitment, combines them with the prime size, derives a
+//     safe prime from the result with DeriveSafePrime, and validates it like
+//     NewParams.
+//
+// Since every player derives the prime themselves from the revealed seeds, all
+// players independently verify the result. The prime size is part of every
+// commitment, so players that disagree on it fail instead of silently deriving
+// different primes. As long as one player's seed is random and kept secret
+// until every commitment is known, nobody can steer the prime.
+type ParamsSetup struct {
+	bits        int
+	seed        []byte
+	commitments [][]byte
+}
+
+// NewParamsSetup creates this player's side of a setup for a safe prime of the
+// given bits. Every player must use the same bits. Params requires at least
+// MinPrimeBits, but note a search that large can take minutes. Smaller sizes
+// are only for testing with UncheckedParams.
+func NewParamsSetup(rnd io.Reader, bits int) (*ParamsSetup, error) {
+	if bits < 16 {
+		return nil, fmt.Errorf("Prime size too small")
+	}
+	s := &ParamsSetup{bits: bits, seed: make([]byte, SetupSeedSize)}
+	if _, err := io.ReadFull(rnd, s.seed); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Commitment is the commitment to this player's seed and the prime size to
+// publish first.
+func (s *ParamsSetup) Commitment() []byte { return commitSeed(s.bits, s.seed) }
+
+// Reveal returns this player's seed given every player's commitment, in the
+// same order every player uses. It fails if this player's commitment is
+// missing. The seed must not be published before every commitment is known.
+func (s *ParamsSetup) Reveal(commitments [][]byte) ([]byte, error) {
+	found := false
+	for _, commitment := range commitments {
+		found = found || bytes.Equal(commitment, s.Commitment())
+	}
+	if !found {
+		return nil, fmt.Errorf("Own commitment missing")
+	}
+	s.commitments = commitments
+	return append([]byte(nil), s.seed...), nil
+}
+
+// Params checks every player's seed against the commitments given to Reveal,
+// in the same order, and returns the params derived from them. The prime is
+// validated with ValidatePrime using MinPrimeBits.
+func (s *ParamsSetup) Params(ctx context.Context, seeds [][]byte) (*Params, error) {
+	p, err := s.derivePrime(ctx, seeds)
+	if err != nil {
+		return nil, err
+	}
+	return NewParams(p)
+}
+
+// UncheckedParams is Params without validating the prime, like
+// NewUncheckedParams. This is only meant for testing with smaller primes.
+func (s *ParamsSetup) UncheckedParams(ctx context.Context, seeds [][]byte) (*Params, error) {
+	p, err := s.derivePrime(ctx, seeds)
+	if err != nil {
+		return nil, err
+	}
+	return NewUncheckedParams(p), nil
+}
+
+func (s *ParamsSetup) derivePrime(ctx context.Context, seeds [][]byte) (*big.Int, error) {
+	if s.commitments == nil {
+		return nil, fmt.Errorf("Seed not revealed")
+	} else if len(seeds) != len(s.commitments) {
+		return nil, fmt.Errorf("Have %v seeds for %v commitments", len(seeds), len(s.commitments))
+	}
+	seen := map[string]bool{}
+	combined := sha256.New()
+	combined.Write([]byte("sra setup seeds v2"))
+	combined.Write(binary.BigEndian.AppendUint32(nil, uint32(s.bits)))
+	for i, seed := range seeds {
+		if len(seed) != SetupSeedSize || !hmac.Equal(commitSeed(s.bits, seed), s.commitments[i]) {
+			return nil, fmt.Errorf("Seed %v does not match commitment", i)
+		} else if seen[string(seed)] {
+			return nil, fmt.Errorf("Seed %v is a duplicate", i)
+		}
+		seen[string(seed)] = true
+		combined.Write(seed)
+	}
+	return DeriveSafePrime(ctx, combined.Sum(nil), s.bits)
+}
+
+func commitSeed(bits int, seed []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("sra setup commitment v2"))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(bits)))
+	h.Write(seed)
+	return h.Sum(nil)
+}
+
+// DeriveSafePrime deterministically derives a safe prime of the given bits
+// from seed. Candidates for q are read from a NewKDF stream of the seed and
+// searched upwards with a sieve on both q and 2q + 1, so the same seed always
+// gives the same prime. It stops with ctx's error when ctx is done.
+func DeriveSafePrime(ctx context.Context, seed []byte, bits int) (*big.Int, error) {
+	if bits < 16 {
+		return nil, fmt.Errorf("Prime size too small")
+	}
+	kdf := NewKDF(seed, nil, []byte("sra safe prime"))
+	max := new(big.Int).Lsh(bigOne, uint(bits-1))
+	residues := make([]uint64, len(sievePrimes))
+	for {
+		// Start at a random odd q of bits - 1 bits with the top two set
+		q, err := rand.Int(kdf, max)
+		if err != nil {
+			return nil, err
+		}
+		q.SetBit(q, bits-2, 1).SetBit(q, bits-3, 1).SetBit(q, 0, 1)
+		for i, prime := range sievePrimes {
+			residues[i] = new(big.Int).Mod(q, new(big.Int).SetUint64(prime)).Uint64()
+		}
+		// Search up from there for a while, then start over
+		for delta := uint64(0); delta < safePrimeSearchWindow; delta += 2 {
+			if delta%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+			if !sieveSafe(residues, delta) {
+				continue
+			}
+			candidate := new(big.Int).Add(q, new(big.Int).SetUint64(delta))
+			if candidate.BitLen() != bits-1 {
+				break
+			}
+			p := new(big.Int).Lsh(candidate, 1)
+			p.Add(p, bigOne)
+			// Cheap Baillie-PSW checks first, then the full check
+			if candidate.ProbablyPrime(0) && p.ProbablyPrime(0) && IsSafePrime(p) {
+				return p, nil
+			}
+		}
+	}
+}
+
+// safePrimeSearchWindow is how far up DeriveSafePrime searches from each
+// starting candidate.
+const safePrimeSearchWindow = 1 << 24
+
+// sieveSafe reports whether neither q + delta nor 2(q + delta) + 1 is divisible
+// by any sieve prime given the residues of q, which must be larger than every
+// sieve prime.
+func sieveSafe(residues []uint64, delta uint64) bool {
+	for i, prime := range sievePrimes {
+		r := (residues[i] + delta%prime) % prime
+		if r == 0 || (2*r+1)%prime == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sievePrimes are the odd primes under 2000 used to sieve candidates.
+var sievePrimes = func() (primes []uint64) {
+	const limit = 2000
+	composite := make([]bool, limit)
+	for i := uint64(2); i < limit; i++ {
+		if !composite[i] {
+			if i > 2 {
+				primes = append(primes, i)
+			}
+			for j := i * i; j < limit; j += i {
+				composite[j] = true
+			}
+		}
+	}
+	return
+}()
@@ -0,0 +1,91 @@
+package sra_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamsSetup(t *testing.T) {
+	// Each player commits, then reveals, then derives
+	setups := make([]*sra.ParamsSetup, 3)
+	commitments := make([][]byte, len(setups))
+	for i := range setups {
+		var err error
+		setups[i], err = sra.NewParamsSetup(rand.Reader, 256)
+		require.NoError(t, err)
+		commitments[i] = setups[i].Commitment()
+	}
+	seeds := make([][]byte, len(setups))
+	for i, setup := range setups {
+		var err error
+		seeds[i], err = setup.Reveal(commitments)
+		require.NoError(t, err)
+	}
+	var params []*sra.Params
+	for _, setup := range setups {
+		p, err := setup.UncheckedParams(context.Background(), seeds)
+		require.NoError(t, err)
+		params = append(params, p)
+	}
+	require.Equal(t, 256, params[0].P.BitLen())
+	require.True(t, sra.IsSafePrime(params[0].P))
+	require.NotNil(t, params[0].Q)
+	require.Equal(t, params[0], params[1])
+	require.Equal(t, params[0], params[2])
+
+	// Checked params need a large enough prime
+	_, err := setups[0].Params(context.Background(), seeds)
+	require.Error(t, err)
+
+	// Changed seeds, missing seeds, duplicated seeds, missing own commitments,
+	// and disagreeing on the prime size all fail
+	badSeed := append([]byte(nil), seeds[1]...)
+	badSeed[0]++
+	_, err = setups[0].UncheckedParams(context.Background(), [][]byte{seeds[0], badSeed, seeds[2]})
+	require.Error(t, err)
+	_, err = setups[0].UncheckedParams(context.Background(), seeds[:2])
+	require.Error(t, err)
+	dupe, err := sra.NewParamsSetup(rand.Reader, 256)
+	require.NoError(t, err)
+	_, err = dupe.Reveal([][]byte{commitments[0], commitments[0], dupe.Commitment()})
+	require.NoError(t, err)
+	_, err = dupe.UncheckedParams(context.Background(), [][]byte{seeds[0], seeds[0], seeds[0]})
+	require.Error(t, err)
+	_, err = dupe.Reveal(commitments)
+	require.Error(t, err)
+	smaller, err := sra.NewParamsSetup(rand.Reader, 128)
+	require.NoError(t, err)
+	mixedCommitments := [][]byte{commitments[0], smaller.Commitment()}
+	smallerSeed, err := smaller.Reveal(mixedCommitments)
+	require.NoError(t, err)
+	_, err = setups[0].Reveal(mixedCommitments)
+	require.NoError(t, err)
+	_, err = setups[0].UncheckedParams(context.Background(), [][]byte{seeds[0], smallerSeed})
+	require.Error(t, err)
+	_, err = smaller.UncheckedParams(context.Background(), [][]byte{seeds[0], smallerSeed})
+	require.Error(t, err)
+	_, err = sra.NewParamsSetup(rand.Reader, 8)
+	require.Error(t, err)
+}
+
+func TestDeriveSafePrime(t *testing.T) {
+	p1, err := sra.DeriveSafePrime(context.Background(), []byte("seed 1"), 128)
+	require.NoError(t, err)
+	p1Again, err := sra.DeriveSafePrime(context.Background(), []byte("seed 1"), 128)
+	require.NoError(t, err)
+	p2, err := sra.DeriveSafePrime(context.Background(), []byte("seed 2"), 128)
+	require.NoError(t, err)
+	require.Equal(t, p1, p1Again)
+	require.NotEqual(t, p1, p2)
+	require.Equal(t, 128, p1.BitLen())
+	require.True(t, sra.IsSafePrime(p1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sra.DeriveSafePrime(ctx, []byte("seed 1"), 2048)
+	require.Equal(t, context.Canceled, err)
+}
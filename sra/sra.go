@@ -1,7 +1,10 @@
 package sra
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"io"
 	"math/big"
 )
@@ -14,38 +17,181 @@ type KeyPair struct {
 	Enc *big.Int
 	// Dec is the big int used to decrypt.
 	Dec *big.Int
+	// ConstantTime, if true, makes EncryptInt and DecryptInt, along with
+	// Prover.Commit and Prover.ProveDecrypt, use fixed-width Montgomery
+	// arithmetic whose timing doesn't depend on Enc, Dec or the proof nonce.
+	// ReencryptInts and RekeyInts also decrypt and encrypt separately instead
+	// of multiplying the exponents. It is slower than the default big.Int
+	// arithmetic which can leak exponent bits through timing. Generating and
+	// validating keys is not constant time. This is not part of the encoded
+	// key pair.
+	ConstantTime bool
 }
 
 var bigOne = big.NewInt(1)
 
-// GenerateKeyPair generates a SRA key pair for the given prime and numBits.
-func GenerateKeyPair(rnd io.Reader, prime *big.Int, numBits int) (kp *KeyPair, err error) {
-	kp = &KeyPair{Prime: prime}
-	phiP := new(big.Int).Sub(prime, bigOne)
-	// TODO: max tries?
-	for {
-		if kp.Enc, err = rand.Prime(rnd, numBits); err != nil {
+// ErrKeyGenExhausted is returned by GenerateKeyPairContext when no suitable
+// exponent was found within the maximum number of attempts. This usually means
+// the params are bad.
+var ErrKeyGenExhausted = errors.New("Key generation attempts exhausted")
+
+// DefaultMaxAttempts is the number of exponents tried when
+// GenerateOptions.MaxAttempts is zero. With reasonable params, almost every
+// attempt succeeds.
+const DefaultMaxAttempts = 1000
+
+// GenerateOptions are options for GenerateKeyPair. The zero value is the
+// hardened default: uniformly random exponents, randomly swapped, at least half
+// the size of the group order.
+type GenerateOptions struct {
+	// PrimeExponentBits, if non-zero, makes Enc a random prime of this many bits
+	// instead of a uniformly random exponent. This was the original behavior
+	// and is much weaker since small exponents can be recovered with
+	// baby-step giant-step from known card values.
+	PrimeExponentBits int
+	// MinExponentBits is the minimum bit size of the uniformly random exponent.
+	// If zero, it is half the bit size of the group order. Its inverse is not
+	// checked since it is just as uniformly random. With PrimeExponentBits this
+	// is only used to reject a PrimeExponentBits below it.
+	MinExponentBits int
+	// NoSwap disables randomly swapping Enc and Dec after they are generated.
+	NoSwap bool
+	// MaxAttempts is the maximum number of exponents to try. If zero,
+	// DefaultMaxAttempts is used.
+	MaxAttempts int
+	// ConstantTime sets KeyPair.ConstantTime on the generated key pair.
+	ConstantTime bool
+}
+
+// GenerateKeyPair generates a SRA key pair for the given params and options.
+// The exponents are inverses mod params.Order(). This is GenerateKeyPairContext
+// with a background context.
+func GenerateKeyPair(rnd io.Reader, params *Params, opts GenerateOptions) (*KeyPair, error) {
+	return GenerateKeyPairContext(context.Background(), rnd, params, opts)
+}
+
+// GenerateKeyPairContext generates a SRA key pair like GenerateKeyPair but
+// stops when ctx is done, returning its error, or when opts.MaxAttempts are
+// exhausted, returning ErrKeyGenExhausted.
+func GenerateKeyPairContext(
+	ctx context.Context,
+	rnd io.Reader,
+	params *Params,
+	opts GenerateOptions,
+) (kp *KeyPair, err error) {
+	kp = &KeyPair{Prime: params.P, ConstantTime: opts.ConstantTime}
+	order := params.Order()
+	minBits := opts.MinExponentBits
+	if opts.PrimeExponentBits != 0 {
+		if opts.PrimeExponentBits < minBits {
+			return nil, fmt.Errorf("Prime exponent bits %v below minimum of %v", opts.PrimeExponentBits, minBits)
+		}
+		// Always exactly the requested size
+		minBits = 0
+	} else if minBits == 0 {
+		minBits = order.BitLen() / 2
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt >= maxAttempts {
+			return nil, ErrKeyGenExhausted
+		} else if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if opts.PrimeExponentBits != 0 {
+			kp.Enc, err = randPrime(rnd, opts.PrimeExponentBits)
+		} else {
+			kp.Enc, err = rand.Int(rnd, order)
+		}
+		if err != nil {
 			return nil, err
 		}
-		if new(big.Int).GCD(nil, nil, kp.Enc, phiP).Cmp(bigOne) == 0 {
+		if kp.Enc.BitLen() >= minBits && new(big.Int).GCD(nil, nil, kp.Enc, order).Cmp(bigOne) == 0 {
 			break
 		}
 	}
-	kp.Dec = new(big.Int).ModInverse(kp.Enc, phiP)
-	// TODO: since direction doesn't matter, I could switch Enc and Dec here.
-	// Should I swap em randomly as shown below?
-	// if v, err := rand.Int(rand.Reader, big.NewInt(2)); err == nil && v.Cmp(bigOne) == 0 {
-	// 	kp.Enc, kp.Dec = kp.Dec, kp.Enc
-	// }
+	kp.Dec = new(big.Int).ModInverse(kp.Enc, order)
+	// Since direction doesn't matter, swap em randomly so that knowing how one
+	// is generated says nothing about the other
+	if !opts.NoSwap {
+		swap, err := rand.Int(rnd, big.NewInt(2))
+		if err != nil {
+			return nil, err
+		} else if swap.Sign() > 0 {
+			kp.Enc, kp.Dec = kp.Dec, kp.Enc
+		}
+	}
 	return
 }
 
-// EncryptInt returns v encrypted with Enc.
-func (k *KeyPair) EncryptInt(v *big.Int) *big.Int {
-	return new(big.Int).Exp(v, k.Enc, k.Prime)
+// randPrime returns a random prime of exactly bits bits read from rnd. Unlike
+// crypto/rand.Prime, this always uses rnd which keeps derived keys
+// deterministic.
+func randPrime(rnd io.Reader, bits int) (*big.Int, error) {
+	if bits < 3 {
+		return nil, fmt.Errorf("Prime size too small")
+	}
+	max := new(big.Int).Lsh(bigOne, uint(bits))
+	for {
+		p, err := rand.Int(rnd, max)
+		if err != nil {
+			return nil, err
+		}
+		// Set the top two bits and make it odd like crypto/rand.Prime
+		p.SetBit(p, bits-1, 1).SetBit(p, bits-2, 1).SetBit(p, 0, 1)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// EncryptInt returns v encrypted with Enc, or nil if v is not Valid.
+func (k *KeyPair) EncryptInt(v *big.Int) *big.Int { return k.exp(v, k.Enc) }
+
+// DecryptInt returns v decrypted with Dec, or nil if v is not Valid.
+func (k *KeyPair) DecryptInt(v *big.Int) *big.Int { return k.exp(v, k.Dec) }
+
+// Valid reports whether v is an element of the group k works in. It must be
+// from 1 to Prime - 1 and, if Prime is a safe prime, a quadratic residue. This
+// keeps a player from marking a card by negating it (i.e. sending p - x), which
+// would make it the only non-residue and let it be followed through the
+// shuffles of every player whose exponent is odd.
+func (k *KeyPair) Valid(v *big.Int) bool {
+	if v == nil || v.Sign() <= 0 || v.Cmp(k.Prime) >= 0 {
+		return false
+	}
+	return !isSafePrimeCached(k.Prime) || big.Jacobi(v, k.Prime) == 1
+}
+
+func (k *KeyPair) exp(v *big.Int, e *big.Int) *big.Int {
+	if !k.Valid(v) {
+		return nil
+	} else if k.ConstantTime {
+		return ctExp(v, e, k.Prime)
+	}
+	return new(big.Int).Exp(v, e, k.Prime)
 }
 
-// DecryptInt returns v decrypted with Dec.
-func (k *KeyPair) DecryptInt(v *big.Int) *big.Int {
-	return new(big.Int).Exp(v, k.Dec, k.Prime)
+// Destroy zeroes Enc and Dec in place, including the words backing them, so
+// the key is no longer in memory. Prime is left alone since it is shared. The
+// key pair must not be used afterwards.
+func (k *KeyPair) Destroy() {
+	zeroInt(k.Enc)
+	zeroInt(k.Dec)
+}
+
+// zeroInt overwrites the words of v and sets it to 0. It does nothing if v is
+// nil.
+func zeroInt(v *big.Int) {
+	if v == nil {
+		return
+	}
+	words := v.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	v.SetInt64(0)
 }
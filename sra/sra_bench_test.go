@@ -10,9 +10,9 @@ import (
 )
 
 // Testing has shown prime size doesn't matter that much
-var smallPrime = genPrime(64)
-var mediumPrime = genPrime(256)
-var largePrime = genPrime(1024)
+var smallPrime = sra.NewUncheckedParams(genPrime(64))
+var mediumPrime = sra.NewUncheckedParams(genPrime(256))
+var largePrime = sra.NewUncheckedParams(genPrime(1024))
 
 func BenchmarkGenerateKeyPairSmallPrime32Bit(b *testing.B) {
 	benchmarkGenerateKeyPair(b, smallPrime, 32)
@@ -56,13 +56,21 @@ func BenchmarkGenerateKeyPairLargePrime2048Bit(b *testing.B) {
 	benchmarkGenerateKeyPair(b, largePrime, 2048)
 }
 
+func BenchmarkGenerateKeyPairDefaultMODP2048(b *testing.B) {
+	benchmarkGenerateKeyPairOpts(b, sra.MODP2048, sra.GenerateOptions{})
+}
+
 var resultKp *sra.KeyPair
 
-func benchmarkGenerateKeyPair(b *testing.B, prime *big.Int, bits int) {
+func benchmarkGenerateKeyPair(b *testing.B, params *sra.Params, bits int) {
+	benchmarkGenerateKeyPairOpts(b, params, sra.GenerateOptions{PrimeExponentBits: bits, NoSwap: true})
+}
+
+func benchmarkGenerateKeyPairOpts(b *testing.B, params *sra.Params, opts sra.GenerateOptions) {
 	var kp *sra.KeyPair
 	var err error
 	for i := 0; i < b.N; i++ {
-		kp, err = sra.GenerateKeyPair(rand.Reader, prime, bits)
+		kp, err = sra.GenerateKeyPair(rand.Reader, params, opts)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -70,6 +78,41 @@ func benchmarkGenerateKeyPair(b *testing.B, prime *big.Int, bits int) {
 	resultKp = kp
 }
 
+func BenchmarkReencryptInts52CardsSerial(b *testing.B)    { benchmarkReencryptInts(b, 52, 1, false) }
+func BenchmarkReencryptInts52CardsParallel(b *testing.B)  { benchmarkReencryptInts(b, 52, 0, false) }
+func BenchmarkReencryptInts52CardsTwoStep(b *testing.B)   { benchmarkReencryptInts(b, 52, 1, true) }
+func BenchmarkReencryptInts104CardsSerial(b *testing.B)   { benchmarkReencryptInts(b, 104, 1, false) }
+func BenchmarkReencryptInts104CardsParallel(b *testing.B) { benchmarkReencryptInts(b, 104, 0, false) }
+func BenchmarkReencryptInts104CardsTwoStep(b *testing.B)  { benchmarkReencryptInts(b, 104, 1, true) }
+
+// benchmarkReencryptInts benchmarks the stage-2 operation. If twoStep is true,
+// it is done the original way with a separate decrypt and encrypt per card.
+func benchmarkReencryptInts(b *testing.B, count int, workers int, twoStep bool) {
+	dec, err := sra.GenerateKeyPair(rand.Reader, sra.MODP2048, sra.GenerateOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	encs := make([]sra.Cipher, count)
+	vals := make([]*big.Int, count)
+	for i := range encs {
+		if encs[i], err = sra.GenerateKeyPair(rand.Reader, sra.MODP2048, sra.GenerateOptions{}); err != nil {
+			b.Fatal(err)
+		} else if vals[i], err = sra.MODP2048.Encode(big.NewInt(int64(i + 2))); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if twoStep {
+			for j, val := range vals {
+				vals[j] = encs[j].EncryptInt(dec.DecryptInt(val))
+			}
+		} else if err = sra.ReencryptInts(dec, encs, vals, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func genPrime(bits int) *big.Int {
 	ret, err := rand.Prime(rand.Reader, bits)
 	if err != nil {
@@ -77,3 +120,26 @@ func genPrime(bits int) *big.Int {
 	}
 	return ret
 }
+
+func BenchmarkEncryptIntMODP2048(b *testing.B) {
+	benchmarkEncryptInt(b, false)
+}
+
+func BenchmarkEncryptIntMODP2048ConstantTime(b *testing.B) {
+	benchmarkEncryptInt(b, true)
+}
+
+func benchmarkEncryptInt(b *testing.B, constantTime bool) {
+	kp, err := sra.GenerateKeyPair(rand.Reader, sra.MODP2048, sra.GenerateOptions{ConstantTime: constantTime})
+	if err != nil {
+		b.Fatal(err)
+	}
+	card, err := sra.MODP2048.Encode(big.NewInt(10))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kp.EncryptInt(card)
+	}
+}
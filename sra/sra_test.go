@@ -1,10 +1,16 @@
 package sra_test
 
 import (
+	"context"
+	"crypto/elliptic"
 	"crypto/rand"
+	"errors"
 	"math/big"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/cretz/go-mental-poker/internal/testutil"
 	"github.com/cretz/go-mental-poker/sra"
 	"github.com/stretchr/testify/require"
 )
@@ -13,16 +19,204 @@ func TestSRACommutative(t *testing.T) {
 	// Gen prime
 	prime, err := rand.Prime(rand.Reader, 256)
 	require.NoError(t, err)
+	params := sra.NewUncheckedParams(prime)
 	// Gen key pairs for alice, bob, and ted
-	alice, err := sra.GenerateKeyPair(rand.Reader, prime, 32)
+	alice, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
 	require.NoError(t, err)
-	bob, err := sra.GenerateKeyPair(rand.Reader, prime, 32)
+	bob, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
 	require.NoError(t, err)
-	ted, err := sra.GenerateKeyPair(rand.Reader, prime, 32)
+	ted, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
 	require.NoError(t, err)
 
 	// Make sure it can be encrypted by all the people in any order, and decrypted
-	peoplePerms := [][]*sra.KeyPair{
+	requireCommutative(t, &sra.SRABackend{Params: params}, alice, bob, ted)
+}
+
+func TestGenerateOptions(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	params := sra.NewUncheckedParams(prime)
+	// Defaults are at least half the order size
+	for i := 0; i < 20; i++ {
+		kp, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+		require.NoError(t, err)
+		require.NoError(t, kp.Validate(params))
+		require.GreaterOrEqual(t, kp.Enc.BitLen(), params.Order().BitLen()/2)
+		require.GreaterOrEqual(t, kp.Dec.BitLen(), params.Order().BitLen()/2)
+	}
+	// Prime exponents can't be below an explicit minimum
+	_, err = sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{PrimeExponentBits: 32, MinExponentBits: 64})
+	require.Error(t, err)
+	// The exponents are swapped randomly unless disabled
+	var smallEnc, smallDec int
+	for i := 0; i < 20; i++ {
+		kp, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{PrimeExponentBits: 32})
+		require.NoError(t, err)
+		if kp.Enc.BitLen() == 32 {
+			smallEnc++
+		} else if kp.Dec.BitLen() == 32 {
+			smallDec++
+		}
+		kp, err = sra.GenerateKeyPair(rand.Reader, params,
+			sra.GenerateOptions{PrimeExponentBits: 32, NoSwap: true})
+		require.NoError(t, err)
+		require.Equal(t, 32, kp.Enc.BitLen())
+		require.True(t, kp.Enc.ProbablyPrime(20))
+	}
+	require.NotZero(t, smallEnc)
+	require.NotZero(t, smallDec)
+}
+
+func TestGenerateKeyPairBounded(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	params := sra.NewUncheckedParams(prime)
+	// Impossible minimum means no exponent ever works
+	_, err = sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{MinExponentBits: 1000, MaxAttempts: 50})
+	require.Equal(t, sra.ErrKeyGenExhausted, err)
+	// Canceled context stops right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sra.GenerateKeyPairContext(ctx, rand.Reader, params, sra.GenerateOptions{})
+	require.Equal(t, context.Canceled, err)
+	_, err = sra.P256.GenerateCipher(ctx, rand.Reader)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestGenerateSafePrimeBounded(t *testing.T) {
+	// A single attempt rarely finds one
+	exhausted := 0
+	for i := 0; i < 20; i++ {
+		p, err := sra.GenerateSafePrimeContext(context.Background(), rand.Reader, 64, 1)
+		if err != nil {
+			require.Equal(t, sra.ErrSafePrimeExhausted, err)
+			exhausted++
+		} else {
+			require.True(t, sra.IsSafePrime(p))
+		}
+	}
+	require.NotZero(t, exhausted)
+	// Canceled context stops right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := sra.GenerateSafePrimeContext(ctx, rand.Reader, 256, 0)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestECCommutative(t *testing.T) {
+	alice, err := sra.GenerateECKeyPair(rand.Reader, elliptic.P256())
+	require.NoError(t, err)
+	bob, err := sra.GenerateECKeyPair(rand.Reader, elliptic.P256())
+	require.NoError(t, err)
+	ted, err := sra.GenerateECKeyPair(rand.Reader, elliptic.P256())
+	require.NoError(t, err)
+	requireCommutative(t, sra.P256, alice, bob, ted)
+	// Encoding round trips through encryption
+	for i := int64(2); i <= 60; i++ {
+		encoded, err := sra.P256.EncodeInt(big.NewInt(i))
+		require.NoError(t, err)
+		decoded, err := sra.P256.DecodeInt(decryptMulti(t, encryptMulti(t, encoded, []sra.Cipher{alice, bob}), []sra.Cipher{alice, bob}))
+		require.NoError(t, err)
+		require.Equal(t, i, decoded.Int64())
+	}
+	// Non-points are rejected
+	require.Nil(t, alice.EncryptInt(big.NewInt(12345)))
+}
+
+func TestBatch(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	for _, backend := range []sra.Backend{&sra.SRABackend{Params: sra.NewUncheckedParams(prime)}, sra.P256} {
+		vals := make([]*big.Int, 30)
+		for i := range vals {
+			vals[i], err = backend.EncodeInt(big.NewInt(int64(i + 2)))
+			require.NoError(t, err)
+		}
+		stage1, err := backend.GenerateCipher(context.Background(), rand.Reader)
+		require.NoError(t, err)
+		stage2 := make([]sra.Cipher, len(vals))
+		for i := range stage2 {
+			stage2[i], err = backend.GenerateCipher(context.Background(), rand.Reader)
+			require.NoError(t, err)
+		}
+		// Encrypt all, re-encrypt with per-value keys, then decrypt each
+		batch := append([]*big.Int{}, vals...)
+		require.NoError(t, sra.EncryptInts(stage1, batch, 4))
+		require.NoError(t, sra.ReencryptInts(stage1, stage2, batch, 4))
+		for i, val := range batch {
+			require.Zero(t, vals[i].Cmp(stage2[i].DecryptInt(val)))
+		}
+		require.NoError(t, sra.EncryptInts(stage1, batch, 0))
+		require.NoError(t, sra.DecryptInts(stage1, batch, 3))
+		for i, val := range batch {
+			require.Zero(t, vals[i].Cmp(stage2[i].DecryptInt(val)))
+		}
+		// And back to a single key
+		require.NoError(t, sra.RekeyInts(stage2, stage1, batch, 2))
+		require.NoError(t, sra.DecryptInts(stage1, batch, 2))
+		for i, val := range batch {
+			require.Zero(t, vals[i].Cmp(val))
+		}
+		require.Error(t, sra.RekeyInts(stage2[1:], stage1, batch, 2))
+	}
+	// Invalid values are reported
+	require.Error(t, sra.EncryptInts(&sra.ECKeyPair{Curve: elliptic.P256(), Enc: big.NewInt(5)},
+		[]*big.Int{big.NewInt(12345)}, 1))
+	kp, err := sra.GenerateKeyPair(rand.Reader, sra.NewUncheckedParams(prime), sra.GenerateOptions{})
+	require.NoError(t, err)
+	require.Error(t, sra.EncryptInts(kp, []*big.Int{big.NewInt(0)}, 1))
+	require.Error(t, sra.DecryptInts(kp, []*big.Int{new(big.Int).Add(prime, big.NewInt(4))}, 1))
+}
+
+// TestKeyPairRefusesInvalid confirms KeyPair returns nil for values outside its
+// group like the Cipher interface requires, even without a safe prime
+func TestKeyPairRefusesInvalid(t *testing.T) {
+	prime := testutil.NewUnsafePrime(t, 256)
+	kp, err := sra.GenerateKeyPair(rand.Reader, sra.NewUncheckedParams(prime), sra.GenerateOptions{})
+	require.NoError(t, err)
+	for _, bad := range []*big.Int{big.NewInt(0), big.NewInt(-4), prime, new(big.Int).Add(prime, big.NewInt(4))} {
+		require.False(t, kp.Valid(bad))
+		require.Nil(t, kp.EncryptInt(bad))
+		require.Nil(t, kp.DecryptInt(bad))
+	}
+	// Anything else in the full group is fine
+	v := new(big.Int).Sub(prime, big.NewInt(2))
+	require.True(t, kp.Valid(v))
+	require.Zero(t, v.Cmp(kp.DecryptInt(kp.EncryptInt(v))))
+}
+
+func TestParallelFor(t *testing.T) {
+	var ran [100]int32
+	require.NoError(t, sra.ParallelFor(len(ran), 4, func(i int) error {
+		atomic.AddInt32(&ran[i], 1)
+		return nil
+	}))
+	for _, count := range ran {
+		require.Equal(t, int32(1), count)
+	}
+	// Both indexes start before either fails, so both errors are kept
+	errs := []error{errors.New("first"), errors.New("second")}
+	var started sync.WaitGroup
+	started.Add(len(errs))
+	err := sra.ParallelFor(len(errs), len(errs), func(i int) error {
+		started.Done()
+		started.Wait()
+		return errs[i]
+	})
+	require.ErrorIs(t, err, errs[0])
+	require.ErrorIs(t, err, errs[1])
+	// No new indexes once one fails
+	var calls int32
+	err = sra.ParallelFor(10, 1, func(i int) error {
+		atomic.AddInt32(&calls, 1)
+		return errs[0]
+	})
+	require.ErrorIs(t, err, errs[0])
+	require.Equal(t, int32(1), calls)
+}
+
+func requireCommutative(t *testing.T, backend sra.Backend, alice, bob, ted sra.Cipher) {
+	peoplePerms := [][]sra.Cipher{
 		{alice, bob, ted},
 		{alice, ted, bob},
 		{bob, alice, ted},
@@ -32,7 +226,8 @@ func TestSRACommutative(t *testing.T) {
 	}
 	// Go over each set of people in any order and make sure the decrypted value always comes out right
 	for _, encPeople := range peoplePerms {
-		superSecretInt := newSuperSecretInt(t, 32)
+		superSecretInt, err := backend.EncodeInt(newSuperSecretInt(t, 32))
+		require.NoError(t, err)
 		encrypted := encryptMulti(t, superSecretInt, encPeople)
 		for _, decPeople := range peoplePerms {
 			decrypted := decryptMulti(t, encrypted, decPeople)
@@ -41,7 +236,41 @@ func TestSRACommutative(t *testing.T) {
 	}
 }
 
-func encryptMulti(t *testing.T, v *big.Int, people []*sra.KeyPair) *big.Int {
+func TestSRAQuadraticResidues(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	require.True(t, sra.IsSafePrime(prime))
+	params := sra.NewUncheckedParams(prime)
+	alice, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+	require.NoError(t, err)
+	bob, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+	require.NoError(t, err)
+	for i := int64(2); i <= 60; i++ {
+		encoded, err := params.Encode(big.NewInt(i))
+		require.NoError(t, err)
+		require.Equal(t, 1, big.Jacobi(encoded, prime))
+		// Encrypted values stay residues and decrypt in any order
+		encrypted := encryptMulti(t, encoded, []sra.Cipher{alice, bob})
+		require.Equal(t, 1, big.Jacobi(encrypted, prime))
+		decoded, err := params.Decode(decryptMulti(t, encrypted, []sra.Cipher{bob, alice}))
+		require.NoError(t, err)
+		require.Equal(t, i, decoded.Int64())
+	}
+	// Non-residues can't be decoded
+	_, err = params.Decode(new(big.Int).Sub(prime, big.NewInt(4)))
+	require.Error(t, err)
+	// Or encrypted, e.g. a card negated to mark it
+	encoded, err := params.Encode(big.NewInt(2))
+	require.NoError(t, err)
+	negated := new(big.Int).Sub(prime, encoded)
+	require.Nil(t, alice.EncryptInt(negated))
+	require.Nil(t, alice.DecryptInt(negated))
+	require.Nil(t, alice.EncryptInt(prime))
+	require.Error(t, sra.EncryptInts(alice, []*big.Int{encoded, negated}, 1))
+	require.Error(t, sra.ReencryptInts(alice, []sra.Cipher{bob, bob}, []*big.Int{encoded, negated}, 1))
+}
+
+func encryptMulti(t *testing.T, v *big.Int, people []sra.Cipher) *big.Int {
 	orig := v
 	for _, person := range people {
 		v = person.EncryptInt(v)
@@ -53,7 +282,7 @@ func encryptMulti(t *testing.T, v *big.Int, people []*sra.KeyPair) *big.Int {
 	return v
 }
 
-func decryptMulti(t *testing.T, v *big.Int, people []*sra.KeyPair) *big.Int {
+func decryptMulti(t *testing.T, v *big.Int, people []sra.Cipher) *big.Int {
 	orig := v
 	for _, person := range people {
 		v = person.DecryptInt(v)
@@ -76,3 +305,25 @@ func newSuperSecretInt(t *testing.T, maxBits int) *big.Int {
 		}
 	}
 }
+
+func TestDestroy(t *testing.T) {
+	kp, err := sra.GenerateKeyPair(rand.Reader, sra.MODP2048, sra.GenerateOptions{})
+	require.NoError(t, err)
+	ec, err := sra.GenerateECKeyPair(rand.Reader, sra.P256.Curve)
+	require.NoError(t, err)
+	vals := []*big.Int{kp.Enc, kp.Dec, ec.Enc, ec.Dec}
+	// Keep the backing words to make sure they are wiped, not just dropped
+	words := make([][]big.Word, len(vals))
+	for i, v := range vals {
+		words[i] = v.Bits()
+		require.NotZero(t, v.Sign())
+	}
+	kp.Destroy()
+	ec.Destroy()
+	for i, v := range vals {
+		require.Zero(t, v.Sign())
+		for _, w := range words[i] {
+			require.Zero(t, w)
+		}
+	}
+}
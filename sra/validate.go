@@ -0,0 +1,64 @@
+package sra
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Validate confirms k is a real SRA key pair for params. This should be used
+// on any key pair received from another player, e.g. when keys are revealed at
+// the end of the game. It checks that the prime is the params' prime, that Enc
+// is invertible and Dec is its inverse mod params.Order(), and that neither
+// exponent is trivial (i.e. 1 or -1 mod the order such as p-2 for the full
+// group) which would leave values unencrypted or just inverted.
+func (k *KeyPair) Validate(params *Params) error {
+	if k.Prime == nil || k.Enc == nil || k.Dec == nil {
+		return fmt.Errorf("Key pair missing values")
+	} else if k.Prime.Cmp(params.P) != 0 {
+		return fmt.Errorf("Key pair prime does not match params")
+	}
+	order := params.Order()
+	orderMinus1 := new(big.Int).Sub(order, bigOne)
+	for _, exp := range []*big.Int{k.Enc, k.Dec} {
+		if exp.Sign() <= 0 || exp.Cmp(params.PMinus1) >= 0 {
+			return fmt.Errorf("Key pair exponent out of range")
+		}
+		if reduced := new(big.Int).Mod(exp, order); reduced.Cmp(bigOne) == 0 || reduced.Cmp(orderMinus1) == 0 {
+			return fmt.Errorf("Key pair exponent is trivial")
+		}
+	}
+	if new(big.Int).GCD(nil, nil, k.Enc, order).Cmp(bigOne) != 0 {
+		return fmt.Errorf("Key pair encryption exponent not invertible")
+	}
+	if product := new(big.Int).Mul(k.Enc, k.Dec); product.Mod(product, order).Cmp(bigOne) != 0 {
+		return fmt.Errorf("Key pair exponents are not inverses")
+	}
+	return nil
+}
+
+// EncryptIntChecked is EncryptInt but fails if v is not Valid or not from 2 to
+// p-2. Values outside that range are fixed points or trivially related to
+// their encryption and should never be received from another player.
+func (k *KeyPair) EncryptIntChecked(v *big.Int) (*big.Int, error) {
+	if err := k.checkInput(v); err != nil {
+		return nil, err
+	}
+	return k.EncryptInt(v), nil
+}
+
+// DecryptIntChecked is DecryptInt but fails like EncryptIntChecked.
+func (k *KeyPair) DecryptIntChecked(v *big.Int) (*big.Int, error) {
+	if err := k.checkInput(v); err != nil {
+		return nil, err
+	}
+	return k.DecryptInt(v), nil
+}
+
+func (k *KeyPair) checkInput(v *big.Int) error {
+	if v == nil || v.Cmp(bigOne) <= 0 || v.Cmp(new(big.Int).Sub(k.Prime, bigOne)) >= 0 {
+		return fmt.Errorf("Value out of range")
+	} else if !k.Valid(v) {
+		return fmt.Errorf("Value not in group")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package sra_test
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/cretz/go-mental-poker/sra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPairValidate(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	params := sra.NewUncheckedParams(prime)
+	kp, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, kp.Validate(params))
+
+	// Wrong params
+	otherPrime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	require.Error(t, kp.Validate(sra.NewUncheckedParams(otherPrime)))
+
+	one := big.NewInt(1)
+	pMinus2 := new(big.Int).Sub(prime, big.NewInt(2))
+	qMinus1 := new(big.Int).Sub(params.Q, one)
+	for _, bad := range []*sra.KeyPair{
+		// Missing
+		{Prime: prime, Enc: kp.Enc},
+		// Not inverses
+		{Prime: prime, Enc: kp.Enc, Dec: new(big.Int).Add(kp.Dec, one)},
+		// Trivial
+		{Prime: prime, Enc: one, Dec: one},
+		{Prime: prime, Enc: pMinus2, Dec: pMinus2},
+		{Prime: prime, Enc: qMinus1, Dec: qMinus1},
+		// Not invertible
+		{Prime: prime, Enc: params.Q, Dec: one},
+		// Out of range
+		{Prime: prime, Enc: new(big.Int).Add(kp.Enc, params.PMinus1), Dec: kp.Dec},
+	} {
+		require.Error(t, bad.Validate(params))
+	}
+}
+
+func TestKeyPairChecked(t *testing.T) {
+	prime, err := sra.GenerateSafePrime(rand.Reader, 256)
+	require.NoError(t, err)
+	params := sra.NewUncheckedParams(prime)
+	kp, err := sra.GenerateKeyPair(rand.Reader, params, sra.GenerateOptions{})
+	require.NoError(t, err)
+	v, err := params.Encode(big.NewInt(2))
+	require.NoError(t, err)
+	enc, err := kp.EncryptIntChecked(v)
+	require.NoError(t, err)
+	dec, err := kp.DecryptIntChecked(enc)
+	require.NoError(t, err)
+	require.Zero(t, v.Cmp(dec))
+	for _, bad := range []*big.Int{
+		big.NewInt(0), big.NewInt(1), params.PMinus1, prime, new(big.Int).Add(prime, big.NewInt(5)), new(big.Int).Sub(prime, v),
+	} {
+		_, err = kp.EncryptIntChecked(bad)
+		require.Error(t, err)
+		_, err = kp.DecryptIntChecked(bad)
+		require.Error(t, err)
+	}
+}